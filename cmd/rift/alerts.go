@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danqzq/rift/internal/alert"
+)
+
+// parseAlerts parses --alert values of the form "key: rule", where key names
+// the route whose region shows the alert.
+func parseAlerts(specs []string, windows alert.Windows) ([]*alert.Alert, error) {
+	alerts := make([]*alert.Alert, 0, len(specs))
+	for _, s := range specs {
+		key, text, ok := strings.Cut(s, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid alert %q, expected key: rule", s)
+		}
+		if _, ok := windows[key]; !ok {
+			return nil, fmt.Errorf("alert %q refers to unknown route %q", s, key)
+		}
+
+		rule, err := alert.ParseRule(text)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert.New(key, rule))
+	}
+	return alerts, nil
+}
+
+// alertHooks builds the hooks selected by the --on-alert and --alert-log
+// flags. The --alert-bell is rung by the renderer, since hooks run on their
+// own goroutine.
+func alertHooks(commands []string, logPath string) []alert.Hook {
+	var hooks []alert.Hook
+	for _, c := range commands {
+		hooks = append(hooks, &alert.CommandHook{Command: c})
+	}
+	if logPath != "" {
+		hooks = append(hooks, &alert.FileHook{Path: logPath})
+	}
+	return hooks
+}
@@ -1,18 +1,33 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/danqzq/rift/internal/chart"
-	"github.com/danqzq/rift/internal/format"
 	"github.com/danqzq/rift/internal/stream"
 )
 
 // Bar command: render all input as a single bar chart.
-func runBar(_ []string) error {
+func runBar(args []string) error {
+	fs := flag.NewFlagSet("bar", flag.ExitOnError)
+	aggName := fs.String("agg", "avg", "aggregation per label: avg, sum, count, last, min, max, pNN, rate, distinct")
+	parse := addParseFlags(fs)
+	fs.Parse(args)
+
+	agg, q, err := chart.ParseAggregation(*aggName)
+	if err != nil {
+		return err
+	}
+	parser, err := parse.parser()
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := setupContext()
 	defer cancel()
 
@@ -28,12 +43,13 @@ func runBar(_ []string) error {
 			if !ok {
 				// EOF - render the bar chart
 				barChart := chart.NewBar(chart.Config{})
+				barChart.Aggregation, barChart.Percentile = agg, q
 				output := barChart.Render(window, 80, 50)
 				fmt.Println(output)
 				return nil
 			}
 
-			result := format.AutoParse(line)
+			result := parser.ParseAt(line, time.Now())
 			for _, point := range result.Points {
 				window.Add(point)
 			}
@@ -46,8 +62,19 @@ func runBar(_ []string) error {
 	}
 }
 
-// Sparkline command: render all input as a sparkline.
+// Sparkline command: render all input as a sparkline, optionally scaled
+// to the min and max given after the flags.
 func runSparkline(args []string) error {
+	fs := flag.NewFlagSet("sparkline", flag.ExitOnError)
+	parse := addParseFlags(fs)
+	fs.Parse(args)
+	args = fs.Args()
+
+	parser, err := parse.parser()
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := setupContext()
 	defer cancel()
 
@@ -89,7 +116,61 @@ func runSparkline(args []string) error {
 				return nil
 			}
 
-			result := format.AutoParse(line)
+			result := parser.ParseAt(line, time.Now())
+			for _, point := range result.Points {
+				window.Add(point)
+			}
+
+		case err := <-reader.Errors():
+			if err != nil {
+				return fmt.Errorf("error reading input: %w", err)
+			}
+		}
+	}
+}
+
+// Table command: render per-label statistics for all input.
+func runTable(args []string) error {
+	fs := flag.NewFlagSet("table", flag.ExitOnError)
+	columns := fs.String("columns", strings.Join(chart.TableColumns, ","), "comma-separated columns to show")
+	sortBy := fs.String("sort", "label", "column to sort by, prefix with - for descending")
+	parse := addParseFlags(fs)
+	fs.Parse(args)
+
+	table := chart.NewTable(chart.Config{})
+	cols, err := chart.ParseColumns(*columns)
+	if err != nil {
+		return err
+	}
+	table.Columns = cols
+	if table.SortBy, table.Desc, err = chart.ParseSort(*sortBy); err != nil {
+		return err
+	}
+	parser, err := parse.parser()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := setupContext()
+	defer cancel()
+
+	reader := stream.NewLineReader(ctx, os.Stdin)
+	window := stream.NewFixedWindow(1000)
+
+	// Read all input
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-reader.Lines():
+			if !ok {
+				// EOF - render the table
+				output := table.Render(window, 120, 50)
+				fmt.Println(output)
+				return nil
+			}
+
+			result := parser.ParseAt(line, time.Now())
 			for _, point := range result.Points {
 				window.Add(point)
 			}
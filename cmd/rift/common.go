@@ -2,10 +2,18 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
+
+	"github.com/danqzq/rift/internal/clock"
+	"github.com/danqzq/rift/internal/format"
+	"github.com/danqzq/rift/internal/source"
+	"github.com/danqzq/rift/internal/stream"
 )
 
 // setupContext creates a context with cancellation and sets up signal handling
@@ -34,3 +42,157 @@ func (a *arrayFlags) Set(value string) error {
 	*a = append(*a, value)
 	return nil
 }
+
+// idleTimer fires when no input has arrived for a duration. A zero duration
+// disables it: C returns a nil channel, which never fires in a select.
+type idleTimer struct {
+	d time.Duration
+	t *time.Timer
+}
+
+func newIdleTimer(d time.Duration) *idleTimer {
+	it := &idleTimer{d: d}
+	if d > 0 {
+		it.t = time.NewTimer(d)
+	}
+	return it
+}
+
+// C returns the channel that receives when the idle duration elapses.
+func (it *idleTimer) C() <-chan time.Time {
+	if it.t == nil {
+		return nil
+	}
+	return it.t.C
+}
+
+// Reset restarts the idle countdown, typically after each input line.
+func (it *idleTimer) Reset() {
+	if it.t != nil {
+		it.t.Reset(it.d)
+	}
+}
+
+// Stop releases the timer.
+func (it *idleTimer) Stop() {
+	if it.t != nil {
+		it.t.Stop()
+	}
+}
+
+// readerFlags holds the input sources and buffering options shared by
+// commands that read input while drawing.
+type readerFlags struct {
+	sources   arrayFlags
+	buffer    *int
+	policy    *string
+	maxLine   *int
+	longLines *string
+}
+
+func addReaderFlags(fs *flag.FlagSet) *readerFlags {
+	f := &readerFlags{
+		buffer:    fs.Int("buffer", stream.DefaultBuffer, "input lines buffered while rendering catches up"),
+		policy:    fs.String("backpressure", string(stream.Block), "when the buffer is full: block, drop-oldest, drop-newest or sample"),
+		maxLine:   fs.Int("max-line", stream.DefaultMaxLine, "maximum input line length in bytes"),
+		longLines: fs.String("long-lines", string(stream.Truncate), "what to do with longer lines: truncate or skip"),
+	}
+	fs.Var(&f.sources, "source", "named input: name=path, name=cmd:command, name=poll:5s:command, or name for stdin (repeatable, default stdin)")
+	return f
+}
+
+// open starts reading the configured sources, or stdin if there are none,
+// polling on clk.
+func (f *readerFlags) open(ctx context.Context, clk clock.Clock) (*source.Input, error) {
+	specs, err := source.ParseAll(f.sources)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := stream.ParsePolicy(*f.policy)
+	if err != nil {
+		return nil, err
+	}
+	longLines, err := stream.ParseLongLines(*f.longLines)
+	if err != nil {
+		return nil, err
+	}
+	return source.Open(ctx, specs, stream.ReaderConfig{
+		Buffer:    *f.buffer,
+		Policy:    policy,
+		MaxLine:   *f.maxLine,
+		LongLines: longLines,
+	}, clk)
+}
+
+// parseFlags holds the options for picking fields out of structured input.
+type parseFlags struct {
+	valuePath *string
+	labelPath *string
+	timePath  *string
+	delimiter *string
+	header    *string
+	pattern   *string
+}
+
+func addParseFlags(fs *flag.FlagSet) *parseFlags {
+	return &parseFlags{
+		valuePath: fs.String("value-path", "", "JSON path or CSV column of the value, e.g. timing.db_ms or $.items[0].ms"),
+		labelPath: fs.String("label-path", "", "JSON path or CSV column of the label, e.g. req.path"),
+		timePath:  fs.String("time-path", "", "JSON path or CSV column of the event timestamp"),
+		delimiter: fs.String("delimiter", "", "CSV delimiter: , ; tab or | (default: detect)"),
+		header:    fs.String("header", "auto", "CSV header row: auto, yes or no"),
+		pattern: fs.String("pattern", "", "regex with named groups (value, value_<name>, label, time, tags) or a preset: "+
+			strings.Join(format.PresetNames(), ", ")),
+	}
+}
+
+// parser returns a parser with the configured paths, CSV options and
+// pattern.
+func (f *parseFlags) parser() (format.Parser, error) {
+	var p format.Parser
+	if *f.pattern != "" {
+		pat, err := format.CompilePattern(*f.pattern)
+		if err != nil {
+			return format.Parser{}, err
+		}
+		p.Pattern = pat
+	}
+	header, err := format.ParseHeaderMode(*f.header)
+	if err != nil {
+		return format.Parser{}, err
+	}
+	p.CSV.Header = header
+	switch d := *f.delimiter; {
+	case d == "":
+	case d == "tab" || d == `\t`:
+		p.CSV.Delimiter = '\t'
+	case len([]rune(d)) == 1 && d != "\"" && d != "\n" && d != "\r":
+		p.CSV.Delimiter = []rune(d)[0]
+	default:
+		return format.Parser{}, fmt.Errorf("invalid delimiter %q", d)
+	}
+	for _, opt := range []struct {
+		arg  string
+		path *format.Path
+	}{
+		{*f.valuePath, &p.JSON.Value},
+		{*f.labelPath, &p.JSON.Label},
+		{*f.timePath, &p.JSON.Time},
+	} {
+		if opt.arg == "" {
+			continue
+		}
+		path, err := format.ParsePath(opt.arg)
+		if err != nil {
+			return format.Parser{}, err
+		}
+		*opt.path = path
+	}
+	return p, nil
+}
+
+// readerStatus describes how many input lines were read, altered and
+// discarded.
+func readerStatus(in *source.Input) string {
+	return fmt.Sprintf("input: %s [%s]", in.Stats(), in.Policy())
+}
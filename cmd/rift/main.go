@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/danqzq/rift/internal/chart"
+	"github.com/danqzq/rift/internal/clock"
 	"github.com/danqzq/rift/internal/format"
+	"github.com/danqzq/rift/internal/source"
 	"github.com/danqzq/rift/internal/stream"
 )
 
@@ -33,12 +38,36 @@ func main() {
 				os.Exit(1)
 			}
 			return
+		case "table":
+			if err := runTable(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		case "sparkline":
 			if err := runSparkline(os.Args[2:]); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
 			return
+		case "record":
+			if err := runRecord(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "replay":
+			if err := runReplay(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "top":
+			if err := runTop(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		case "-h", "--help", "help":
 			printHelp()
 			return
@@ -46,10 +75,16 @@ func main() {
 	}
 
 	// Default behavior: simple display mode
-	runSimpleMode()
+	runSimpleMode(os.Args[1:])
 }
 
-func runSimpleMode() {
+func runSimpleMode(args []string) {
+	fs := flag.NewFlagSet("rift", flag.ExitOnError)
+	exitOnIdle := fs.Duration("exit-on-idle", 0, "exit after this long without input (e.g. 30s)")
+	input := addReaderFlags(fs)
+	parse := addParseFlags(fs)
+	fs.Parse(args)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -61,26 +96,44 @@ func runSimpleMode() {
 		cancel()
 	}()
 
-	reader := stream.NewLineReader(ctx, os.Stdin)
+	parser, err := parse.parser()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	reader, err := input.open(ctx, clock.Real)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	parsers := source.NewParsers(parser)
 	window := stream.NewFixedWindow(100)
 
 	fmt.Fprintln(os.Stderr, "rift: Waiting for input... (Ctrl+C to exit)")
 
+	idle := newIdleTimer(*exitOnIdle)
+	defer idle.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-idle.C():
+			fmt.Fprintf(os.Stderr, "\nNo input for %s, exiting.\n", *exitOnIdle)
+			printSummary(window, reader.Stats())
+			return
 		case line, ok := <-reader.Lines():
 			if !ok {
-				printSummary(window)
+				printSummary(window, reader.Stats())
 				return
 			}
+			idle.Reset()
 
-			result := format.AutoParse(line)
+			result := parsers.Parse(line, time.Now())
 
 			for _, point := range result.Points {
 				window.Add(point)
-				displayPoint(point, result.Format)
+				displayPoint(point, result.Format, len(input.sources) > 1)
 			}
 
 		case err := <-reader.Errors():
@@ -91,25 +144,36 @@ func runSimpleMode() {
 	}
 }
 
-func displayPoint(p stream.DataPoint, f format.FormatType) {
+func displayPoint(p stream.DataPoint, f format.FormatType, showSource bool) {
+	prefix := fmt.Sprintf("[%s] ", f)
+	if showSource {
+		prefix += p.Source + " "
+	}
+	value := chart.FormatValue(p.Value, p.Unit)
 	if p.Label != "" {
-		fmt.Printf("[%s] %s: %.2f\n", f, p.Label, p.Value)
+		fmt.Printf("%s%s: %s\n", prefix, p.Label, value)
 	} else {
-		fmt.Printf("[%s] %.2f\n", f, p.Value)
+		fmt.Printf("%s%s\n", prefix, value)
 	}
 }
 
-func printSummary(w *stream.Window) {
+func printSummary(w *stream.Window, input stream.ReaderStats) {
+	if !input.Intact() {
+		fmt.Fprintf(os.Stderr, "\nInput: %s\n", input)
+	}
 	if w.Len() == 0 {
 		fmt.Fprintln(os.Stderr, "\nNo data points received.")
 		return
 	}
 
-	min, max := w.Scale()
+	stats := w.Stats()
 	fmt.Fprintf(os.Stderr, "\n--- Summary ---\n")
-	fmt.Fprintf(os.Stderr, "Points: %d\n", w.Len())
-	fmt.Fprintf(os.Stderr, "Min: %.2f\n", min)
-	fmt.Fprintf(os.Stderr, "Max: %.2f\n", max)
+	fmt.Fprintf(os.Stderr, "Points: %d\n", stats.Count)
+	fmt.Fprintf(os.Stderr, "Min: %.2f\n", stats.Min)
+	fmt.Fprintf(os.Stderr, "Max: %.2f\n", stats.Max)
+	fmt.Fprintf(os.Stderr, "Mean: %.2f\n", stats.Mean)
+	fmt.Fprintf(os.Stderr, "StdDev: %.2f\n", stats.StdDev)
+	fmt.Fprintf(os.Stderr, "p50/p95/p99: %.2f / %.2f / %.2f\n", stats.P50, stats.P95, stats.P99)
 }
 
 func printHelp() {
@@ -117,14 +181,34 @@ func printHelp() {
 
 USAGE:
     rift [COMMAND]
+    rift [--exit-on-idle DURATION]
 
 COMMANDS:
     bar          Render input as a bar chart
     sparkline    Render input as a sparkline
+    table        Render per-label statistics as a table
     split        Route single input stream to multiple charts
     grid         Compose multiple streams into a grid layout
+    record       Save input lines with their arrival times (rift record -o session.rift)
+    replay       Play a recorded session through split (rift replay session.rift --speed 10x --route ...)
+    top          System metrics dashboard from /proc (rift top --pid 1234)
     help         Show this message
 
+Read several inputs at once with --source name=path or --source name='cmd:command',
+run a command periodically with --source name='poll:5s:command' (options after
+the interval: poll:5s,timeout=2s,format=json:command), collect Linux metrics
+such as cpu.busy or net.eth0.rx_bytes_per_s with --source name=sys[:1s,pid=N],
+and route by input with selectors such as "source=db" or "source=db,latency".
+
+Numbers in nested JSON become dotted labels (cpu.user); pick fields with --value-path,
+--label-path and --time-path. CSV header rows name the columns, and the same
+flags choose columns; use --delimiter ';' for decimal-comma spreadsheets.
+Parse log lines with --pattern '(?P<label>\S+) (?P<status>\d+) (?P<value>\d+)ms'
+or a preset (nginx, apache, gobench, ping, iostat); other groups are tags that
+selectors such as "status=500" match.
+Values with units (250ms, 1m30s, 512KiB, 3.2GB, 45%) are converted to seconds,
+bytes or percent and shown with a fitting prefix, so 900ms and 1.2s share a scale.
+
 Run 'rift split -h' or 'rift grid -h' for command-specific help.
 
 When run without commands, rift reads from stdin and displays parsed values.`)
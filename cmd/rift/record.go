@@ -0,0 +1,323 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/danqzq/rift/internal/clock"
+	"github.com/danqzq/rift/internal/format"
+	"github.com/danqzq/rift/internal/layout"
+	"github.com/danqzq/rift/internal/session"
+	"github.com/danqzq/rift/internal/source"
+)
+
+// Record command: save input lines with their arrival times, passing them
+// through to stdout so record can sit in the middle of a pipe.
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	output := fs.String("o", "", "session file to write (required)")
+	quiet := fs.Bool("q", false, "do not copy input to stdout")
+	input := addReaderFlags(fs)
+	fs.Parse(args)
+
+	if *output == "" {
+		return fmt.Errorf("no output file, use -o session.rift")
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := session.NewWriter(f)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := setupContext()
+	defer cancel()
+
+	reader, err := input.open(ctx, clock.Real)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !reader.Stats().Intact() {
+			fmt.Fprintf(os.Stderr, "rift: %s\n", readerStatus(reader))
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-reader.Lines():
+			if !ok {
+				return nil
+			}
+			at := line.Time
+			if at.IsZero() {
+				at = time.Now()
+			}
+			rec := session.Record{Time: at, Source: line.Source, Line: line.Text, Format: string(line.Format)}
+			if err := w.Write(rec); err != nil {
+				return err
+			}
+			if !*quiet {
+				fmt.Println(line.Text)
+			}
+		case err := <-reader.Errors():
+			// Source errors, such as a failed poll, are not fatal: the
+			// other sources and later runs keep being recorded
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}
+	}
+}
+
+// replayKeys are the interactive replay controls, shown in the status line.
+const replayKeys = "[space] pause  [←/→] seek 10s  [n] step  [+/-] speed  [q] quit"
+
+// Replay command: feed a recorded session through the split pipeline with
+// its original timing. Points are stamped with their recorded arrival time
+// and the dashboard runs on a fake clock that follows the session, so
+// windows, staleness and alerts match the original run.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags := addSplitFlags(fs)
+	speedArg := fs.String("speed", "1x", "playback speed, e.g. 10x, 0.5x or max")
+	once := fs.Bool("once", false, "replay instantly and print the final frame as plain text")
+
+	// Accept the session file before or after the flags
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if path == "" {
+		if fs.NArg() < 1 {
+			return fmt.Errorf("session file required, e.g. rift replay session.rift")
+		}
+		path = fs.Arg(0)
+	}
+	speed, err := session.ParseSpeed(*speedArg)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	records, err := session.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if *once {
+		return replayOnce(os.Stdout, flags, records)
+	}
+
+	termWidth, termHeight, _ := layout.GetTerminalSize()
+	dashHeight := max(termHeight-1, 1) // last row is the status line
+	player := session.NewPlayer(records, speed)
+	clk := clock.NewFake(player.Now())
+	dash, err := flags.newDashboard(termWidth, dashHeight, clk)
+	if err != nil {
+		return err
+	}
+	defer func() { dash.close() }()
+
+	ctx, cancel := setupContext()
+	defer cancel()
+
+	// Read keys from the terminal in raw mode when stdin is interactive.
+	// Otherwise keys stays nil, so replay exits once the session ends
+	var keys chan byte
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		keys = make(chan byte)
+		state, err := term.MakeRaw(fd)
+		if err == nil {
+			defer term.Restore(fd, state)
+		}
+		go func() {
+			buf := make([]byte, 16)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					close(keys)
+					return
+				}
+				for _, b := range buf[:n] {
+					keys <- b
+				}
+			}
+		}()
+	}
+
+	renderer := dash.renderer()
+	play := func(recs []session.Record) {
+		for _, rec := range recs {
+			clk.Set(rec.Time)
+			dash.ingest(recordLine(rec), rec.Time)
+		}
+		clk.Set(player.Now())
+	}
+	seek := func(pos time.Duration) {
+		recs, reset := player.Seek(pos)
+		if reset {
+			dash.close()
+			clk.Set(player.Now())
+			if dash, err = flags.newDashboard(termWidth, dashHeight, clk); err != nil {
+				cancel()
+				return
+			}
+			renderer = dash.renderer()
+		}
+		play(recs)
+	}
+
+	layout.HideCursor()
+	defer layout.ShowCursor()
+	renderer.Clear()
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	last := time.Now()
+	var escape []byte // partial arrow-key escape sequence
+	flushed := false
+	frames := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return err
+
+		case b, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			// Arrow keys arrive as ESC [ C / ESC [ D
+			if b == 0x1b || len(escape) > 0 {
+				escape = append(escape, b)
+				if len(escape) < 3 {
+					continue
+				}
+				switch string(escape) {
+				case "\x1b[C":
+					b = 'l'
+				case "\x1b[D":
+					b = 'h'
+				}
+				escape = nil
+			}
+			switch b {
+			case 'q', 0x03:
+				return nil
+			case ' ':
+				player.TogglePause()
+			case 'n', '.':
+				play(player.Step())
+			case 'l':
+				seek(player.Position() + 10*time.Second)
+			case 'h':
+				seek(player.Position() - 10*time.Second)
+			case 'g':
+				seek(0)
+			case '+':
+				player.SetSpeed(player.Speed() * 2)
+			case '-':
+				player.SetSpeed(player.Speed() / 2)
+			}
+			flushed = false
+
+		case now := <-ticker.C:
+			play(player.Advance(now.Sub(last)))
+			last = now
+
+			if player.Done() && !flushed {
+				dash.flush()
+				flushed = true
+				if keys == nil {
+					// Not interactive: show the end state briefly and exit
+					dash.evaluate(player.Now())
+					renderer.Clear()
+					renderer.Render()
+					time.Sleep(2 * time.Second)
+					return nil
+				}
+			}
+
+			// Redraw every 100ms
+			if frames++; frames%5 != 0 {
+				continue
+			}
+			dash.evaluate(player.Now())
+			renderer.Clear()
+			renderer.Render()
+			layout.MoveCursor(0, dashHeight)
+			fmt.Print(replayStatus(player, termWidth))
+		}
+	}
+}
+
+// replayOnce plays a whole session instantly and writes the final frame to out.
+func replayOnce(out io.Writer, flags *splitFlags, records []session.Record) error {
+	width, height := 80, 24
+	if len(flags.routes) > 0 {
+		height = 6 * len(flags.routes)
+	}
+	player := session.NewPlayer(records, math.Inf(1))
+	clk := clock.NewFake(player.Now())
+	dash, err := flags.newDashboard(width, height, clk)
+	if err != nil {
+		return err
+	}
+	defer dash.close()
+
+	for _, rec := range player.Advance(0) {
+		clk.Set(rec.Time)
+		dash.ingest(recordLine(rec), rec.Time)
+	}
+	dash.flush()
+	dash.evaluate(player.Now())
+	_, err = fmt.Fprintln(out, dash.renderer().Frame())
+	return err
+}
+
+// recordLine turns a recorded line back into the line read from its source.
+func recordLine(rec session.Record) source.Line {
+	return source.Line{Source: rec.Source, Text: rec.Line, Format: format.FormatType(rec.Format)}
+}
+
+// replayStatus describes the playback position, speed and controls.
+func replayStatus(p *session.Player, width int) string {
+	state := "▶"
+	if p.Paused() {
+		state = "⏸"
+	}
+	if p.Done() {
+		state = "■"
+	}
+	speed := "max"
+	if !math.IsInf(p.Speed(), 1) {
+		speed = fmt.Sprintf("%gx", p.Speed())
+	}
+	status := fmt.Sprintf("%s %s / %s  %s  %s", state,
+		p.Position().Truncate(time.Second), p.Duration().Truncate(time.Second), speed, replayKeys)
+	if r := []rune(status); len(r) > width {
+		status = string(r[:width])
+	}
+	return "\033[7m" + status + "\033[0m"
+}
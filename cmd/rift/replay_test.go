@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danqzq/rift/internal/session"
+)
+
+func TestReplayOnce(t *testing.T) {
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	var records []session.Record
+	for i, line := range []string{"cpu,10", "mem,5", "cpu,20", "cpu,30", "mem,15"} {
+		records = append(records, session.Record{Time: start.Add(time.Duration(i) * time.Second), Line: line})
+	}
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags := addSplitFlags(fs)
+	if err := fs.Parse([]string{"--route", "cpu:sparkline", "--route", "mem:counter big=false delta=false"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the same session must give the same frame every time
+	var frames []string
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		if err := replayOnce(&out, flags, records); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, out.String())
+	}
+
+	if frames[0] != frames[1] {
+		t.Errorf("replay is not deterministic:\n%s\n---\n%s", frames[0], frames[1])
+	}
+	for _, want := range []string{"cpu: ▁▄█", "mem: 15.00"} {
+		if !strings.Contains(frames[0], want) {
+			t.Errorf("frame should contain %q, got:\n%s", want, frames[0])
+		}
+	}
+}
+
+func TestReplayOnce_Selectors(t *testing.T) {
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	var records []session.Record
+	for i, r := range []struct{ source, line string }{
+		{"db", "latency,7"},
+		{"api", "latency,3"},
+		{"db", "conns,40"},
+		{"web", "GET /a 500 12"},
+		{"web", "GET /b 200 4"},
+	} {
+		records = append(records, session.Record{Time: start.Add(time.Duration(i) * time.Second), Source: r.source, Line: r.line})
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"source", []string{"--route", "source=db:counter big=false delta=false"}, "source=db: 40.00"},
+		{"source and label", []string{"--route", "source=db,latency:counter big=false delta=false"}, "source=db,latency: 7.00"},
+		{"api source", []string{"--route", "source=api:counter big=false delta=false"}, "source=api: 3.00"},
+		{"tag", []string{"--pattern", `(?P<method>\S+) (?P<label>\S+) (?P<status>\d+) (?P<value>\d+)`,
+			"--route", "status=500:counter big=false delta=false"}, "status=500: 12.00"},
+		{"plain key", []string{"--route", "conns:counter big=false delta=false"}, "conns: 40.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+			flags := addSplitFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := replayOnce(&out, flags, records); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("frame should contain %q, got:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestReplayOnce_PlainText(t *testing.T) {
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	var records []session.Record
+	for i, line := range []string{"cpu,90", "cpu,95"} {
+		records = append(records, session.Record{Time: start.Add(time.Duration(i) * time.Second), Line: line})
+	}
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags := addSplitFlags(fs)
+	if err := fs.Parse([]string{"--route", "cpu:counter big=false", "--alert", "cpu: last(cpu) > 80"}); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := replayOnce(&out, flags, records); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "FIRING") {
+		t.Fatalf("frame should show the firing alert, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "\x1b") {
+		t.Errorf("frame should be plain text, got %q", out.String())
+	}
+}
+
+func TestReplayOnce_RecordedFormat(t *testing.T) {
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags := addSplitFlags(fs)
+	if err := fs.Parse([]string{"--route", "cpu:counter big=false delta=false"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A source that forced the raw format must not be detected as CSV
+	for format, want := range map[string]string{"": "cpu: 5.00", "raw": "cpu: no data"} {
+		records := []session.Record{{Time: start, Source: "du", Line: "cpu,5", Format: format}}
+		var out bytes.Buffer
+		if err := replayOnce(&out, flags, records); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("format %q: frame should contain %q, got:\n%s", format, want, out.String())
+		}
+	}
+}
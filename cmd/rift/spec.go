@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danqzq/rift/internal/chart"
+	"github.com/danqzq/rift/internal/transform"
+)
+
+// routeSpec is a parsed --route value of the form
+// "key:charttype [option=value ...]". The transform option applies to every
+// chart type and is kept separately from the chart options.
+type routeSpec struct {
+	Key       string
+	ChartType string
+	Options   map[string]string
+	Transform transform.Pipeline
+}
+
+// parseRouteSpec parses a route specification such as
+// "latency:table cols=last,p95 sort=-p95" or "bytes:sparkline transform=rate|ewma(0.3)".
+func parseRouteSpec(s string) (routeSpec, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return routeSpec{}, fmt.Errorf("invalid route spec %q, expected key:charttype", s)
+	}
+
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return routeSpec{}, fmt.Errorf("invalid route spec %q, missing chart type", s)
+	}
+
+	spec := routeSpec{
+		Key:       strings.TrimSpace(parts[0]),
+		ChartType: fields[0],
+		Options:   make(map[string]string),
+	}
+
+	for _, opt := range fields[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return routeSpec{}, fmt.Errorf("invalid option %q in route spec %q, expected name=value", opt, s)
+		}
+		if kv[0] == "transform" {
+			pl, err := transform.Parse(kv[1])
+			if err != nil {
+				return routeSpec{}, err
+			}
+			spec.Transform = pl
+			continue
+		}
+		spec.Options[kv[0]] = kv[1]
+	}
+
+	return spec, nil
+}
+
+// newChart creates the chart for a route spec, applying its options.
+func newChart(spec routeSpec) (chart.Chart, error) {
+	config := chart.Config{Label: spec.Key}
+	opts := spec.Options
+	used := make(map[string]bool)
+
+	var c chart.Chart
+	switch spec.ChartType {
+	case "sparkline":
+		sp := chart.NewSparkline(config)
+		if v, ok := opts["mode"]; ok {
+			if v != "time" && v != "points" {
+				return nil, fmt.Errorf("invalid sparkline mode %q, expected time or points", v)
+			}
+			sp.TimeMode = v == "time"
+			used["mode"] = true
+		}
+		if v, ok := opts["span"]; ok {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid span %q", v)
+			}
+			sp.Span = d
+			sp.TimeMode = true
+			used["span"] = true
+		}
+		if v, ok := opts["agg"]; ok {
+			agg, _, err := chart.ParseAggregation(v)
+			if err != nil {
+				return nil, err
+			}
+			if !chart.CanBucket(agg) {
+				return nil, fmt.Errorf("sparkline cannot aggregate by %s, expected avg, min, max, last, sum, count or rate", v)
+			}
+			sp.Aggregation = agg
+			used["agg"] = true
+		}
+		if v, ok := opts["band"]; ok {
+			band, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid band %q", v)
+			}
+			sp.Band = band
+			used["band"] = true
+		}
+		c = sp
+	case "bar":
+		b := chart.NewBar(config)
+		if v, ok := opts["agg"]; ok {
+			agg, q, err := chart.ParseAggregation(v)
+			if err != nil {
+				return nil, err
+			}
+			b.Aggregation, b.Percentile = agg, q
+			used["agg"] = true
+		}
+		c = b
+	case "column", "stacked":
+		col := chart.NewColumn(config)
+		col.Stacked = spec.ChartType == "stacked"
+		if v, ok := opts["agg"]; ok {
+			agg, q, err := chart.ParseAggregation(v)
+			if err != nil {
+				return nil, err
+			}
+			col.Aggregation, col.Percentile = agg, q
+			used["agg"] = true
+		}
+		if v, ok := opts["bucket"]; ok {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid bucket %q", v)
+			}
+			col.Bucket = d
+			used["bucket"] = true
+		}
+		c = col
+	case "counter":
+		cnt := chart.NewCounter(config)
+		for name, field := range map[string]*bool{"rate": &cnt.ShowRate, "delta": &cnt.ShowDelta, "big": &cnt.BigDigits} {
+			if v, ok := opts[name]; ok {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s %q", name, v)
+				}
+				*field = b
+				used[name] = true
+			}
+		}
+		c = cnt
+	case "table":
+		t := chart.NewTable(config)
+		if v, ok := opts["cols"]; ok {
+			cols, err := chart.ParseColumns(v)
+			if err != nil {
+				return nil, err
+			}
+			t.Columns = cols
+			used["cols"] = true
+		}
+		if v, ok := opts["sort"]; ok {
+			col, desc, err := chart.ParseSort(v)
+			if err != nil {
+				return nil, err
+			}
+			t.SortBy, t.Desc = col, desc
+			used["sort"] = true
+		}
+		c = t
+	default:
+		return nil, fmt.Errorf("unknown chart type %q", spec.ChartType)
+	}
+
+	if err := checkOptions(spec, used); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkOptions reports options that were not consumed by the chart.
+func checkOptions(spec routeSpec, used map[string]bool) error {
+	var unknown []string
+	for name := range spec.Options {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown option(s) %s for %s chart", strings.Join(unknown, ", "), spec.ChartType)
+	}
+	return nil
+}
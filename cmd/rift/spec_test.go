@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestNewChart_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"unknown chart", "cpu:pie"},
+		{"unknown option", "cpu:bar color=red"},
+		{"sparkline percentile", "cpu:sparkline agg=p95"},
+		{"sparkline distinct", "cpu:sparkline agg=distinct"},
+		{"bad span", "cpu:sparkline span=soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := parseRouteSpec(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := newChart(spec); err == nil {
+				t.Errorf("newChart(%q) should fail", tt.spec)
+			}
+		})
+	}
+
+	for _, s := range []string{"cpu:sparkline agg=max", "cpu:sparkline agg=rate span=1m", "cpu:bar agg=p95"} {
+		spec, err := parseRouteSpec(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newChart(spec); err != nil {
+			t.Errorf("newChart(%q) = %v", s, err)
+		}
+	}
+}
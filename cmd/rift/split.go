@@ -7,110 +7,358 @@ import (
 	"strings"
 	"time"
 
-	"github.com/danqzq/rift/internal/chart"
-	"github.com/danqzq/rift/internal/format"
+	"github.com/danqzq/rift/internal/alert"
+	"github.com/danqzq/rift/internal/analysis"
+	"github.com/danqzq/rift/internal/clock"
+	"github.com/danqzq/rift/internal/derive"
+	"github.com/danqzq/rift/internal/expr"
 	"github.com/danqzq/rift/internal/layout"
 	"github.com/danqzq/rift/internal/route"
+	"github.com/danqzq/rift/internal/source"
 	"github.com/danqzq/rift/internal/stream"
 )
 
-// Split command: route a single input to multiple charts.
-func runSplit(args []string) error {
-	fs := flag.NewFlagSet("split", flag.ExitOnError)
-	field := fs.String("field", "label", "field to route on")
-	var routes arrayFlags
-	fs.Var(&routes, "route", "routing rule: key:charttype (repeatable)")
-	fs.Parse(args)
+// splitFlags holds the options of the split command, which replay shares.
+type splitFlags struct {
+	field         *string
+	history       *time.Duration
+	span          *time.Duration
+	eventTime     *bool
+	lateness      *time.Duration
+	staleAfter    *time.Duration
+	noData        *string
+	deriveBucket  *time.Duration
+	deriveFill    *string
+	derived       arrayFlags
+	anomaly       *string
+	anomalyK      *float64
+	anomalyWindow *int
+	changePoints  *bool
+	alertRules    arrayFlags
+	alertCmds     arrayFlags
+	alertBell     *bool
+	alertLog      *string
+	parse         *parseFlags
+	routes        arrayFlags
+}
 
-	if len(routes) == 0 {
-		return fmt.Errorf("no routes specified, use --route flag")
-	}
+// addSplitFlags registers the split options on fs.
+func addSplitFlags(fs *flag.FlagSet) *splitFlags {
+	f := &splitFlags{}
+	f.field = fs.String("field", "label", "field to route on")
+	f.history = fs.Duration("history", 0, "keep downsampled history for this long (e.g. 6h) for time-mode sparklines")
+	f.span = fs.Duration("window", 0, "keep points from this time span instead of the last 100 points")
+	f.eventTime = fs.Bool("event-time", false, "evict by timestamps parsed from the input instead of arrival time")
+	f.lateness = fs.Duration("lateness", 0, "with --event-time, how long to wait for out-of-order points")
+	f.staleAfter = fs.Duration("stale", 0, "mark regions stale after this long without new points (e.g. 10s)")
+	f.noData = fs.String("no-data", layout.DefaultNoData, "text shown in regions without data")
+	f.deriveBucket = fs.Duration("derive-bucket", time.Second, "time bucket used to align series for --derive")
+	f.deriveFill = fs.String("derive-fill", "skip", "how --derive treats a series missing from a bucket: skip, zero or prev")
+	fs.Var(&f.derived, "derive", "derived series: \"name = expression\", e.g. \"error_rate = errors / requests * 100\" (repeatable)")
+	f.anomaly = fs.String("anomaly", "", "flag outliers per route using a rolling zscore or mad band")
+	f.anomalyK = fs.Float64("anomaly-k", 3, "band width for --anomaly, in standard deviations")
+	f.anomalyWindow = fs.Int("anomaly-window", 60, "baseline size for --anomaly and --changepoints, in points")
+	f.changePoints = fs.Bool("changepoints", false, "detect level shifts per route with CUSUM")
+	fs.Var(&f.alertRules, "alert", "alert rule for a route: \"key: expr > threshold [for 30s] [clear N]\", e.g. \"latency: p95(latency) > 300 for 30s\" (repeatable)")
+	fs.Var(&f.alertCmds, "on-alert", "shell command run on alert changes, with the event as JSON on stdin (repeatable)")
+	f.alertBell = fs.Bool("alert-bell", false, "ring the terminal bell when an alert fires")
+	f.alertLog = fs.String("alert-log", "", "append alert events to this file as JSON lines")
+	f.parse = addParseFlags(fs)
+	fs.Var(&f.routes, "route", "routing rule: \"key:charttype [option=value ...]\", e.g. \"bytes:sparkline transform=rate|ewma(0.3)\" (repeatable)")
+	return f
+}
 
-	ctx, cancel := setupContext()
-	defer cancel()
+// dashboard is the split pipeline: parsed lines are routed, transformed and
+// derived into windows, which regions render and alerts watch.
+type dashboard struct {
+	parsers *source.Parsers
+	router  *route.Router
+	regions []*layout.Region
+	deriver *derive.Deriver
+	windows alert.Windows
+	alerts  []*alert.Alert
+	engine  *alert.Engine
+	clock   clock.Clock
+	bell    bool             // ring the bell when an alert fires
+	render  *layout.Renderer // the renderer that rings it
+}
 
-	router := route.NewRouter()
-	regions := make([]*layout.Region, 0, len(routes))
+// newDashboard builds the pipeline described by the flags, with regions
+// stacked to fill width x height and windows running on clk.
+func (f *splitFlags) newDashboard(width, height int, clk clock.Clock) (*dashboard, error) {
+	if len(f.routes) == 0 {
+		return nil, fmt.Errorf("no routes specified, use --route flag")
+	}
 
-	termWidth, termHeight, _ := layout.GetTerminalSize()
-	regionHeight := termHeight / len(routes)
+	var method analysis.Method
+	if *f.anomaly != "" {
+		m, err := analysis.ParseMethod(*f.anomaly)
+		if err != nil {
+			return nil, err
+		}
+		method = m
+	}
+
+	parser, err := f.parse.parser()
+	if err != nil {
+		return nil, err
+	}
+	d := &dashboard{parsers: source.NewParsers(parser), router: route.NewRouter(), clock: clk}
 
-	for i, routeSpec := range routes {
-		parts := strings.SplitN(routeSpec, ":", 2)
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid route spec %q, expected key:charttype", routeSpec)
+	if len(f.derived) > 0 {
+		fill, err := derive.ParseFill(*f.deriveFill)
+		if err != nil {
+			return nil, err
+		}
+		defs := make([]expr.Definition, 0, len(f.derived))
+		for _, s := range f.derived {
+			def, err := expr.ParseDefinition(s)
+			if err != nil {
+				return nil, err
+			}
+			// Derived series are computed from one time bucket of values,
+			// which keeps no history for window functions to run over
+			if fn, ok := expr.HistoryFunc(def.Expr); ok {
+				return nil, fmt.Errorf("derive %s: %s() needs a series' history, which --derive does not keep; use it in --alert", def.Name, fn)
+			}
+			defs = append(defs, def)
 		}
+		d.deriver = derive.New(defs, *f.deriveBucket, fill)
+	}
+
+	regionHeight := height / len(f.routes)
+	d.windows = make(alert.Windows, len(f.routes))
 
-		key := strings.TrimSpace(parts[0])
-		chartType := strings.TrimSpace(parts[1])
+	for i, routeArg := range f.routes {
+		spec, err := parseRouteSpec(routeArg)
+		if err != nil {
+			return nil, err
+		}
+		key := spec.Key
 
+		// Keys written as selectors ("source=db", "source=db,latency", "*")
+		// are parsed; plain keys match the --field
 		var sel route.Selector
-		if *field != "" {
-			sel = route.NewFieldSelector(*field, key)
+		if *f.field != "" && !strings.ContainsAny(key, "=,*") {
+			sel = route.NewFieldSelector(*f.field, key)
 		} else {
 			sel = route.ParseSelector(key)
 		}
 
-		var c chart.Chart
-		config := chart.Config{Label: key}
-
-		switch chartType {
-		case "sparkline":
-			c = chart.NewSparkline(config)
-		case "bar":
-			c = chart.NewBar(config)
-		case "counter":
-			c = chart.NewCounter(config)
-		default:
-			return fmt.Errorf("unknown chart type %q", chartType)
+		c, err := newChart(spec)
+		if err != nil {
+			return nil, err
 		}
 
-		w := stream.NewFixedWindow(100)
-		router.AddRoute(&route.Route{
+		config := stream.WindowConfig{
+			MaxSize:         100,
+			EventTime:       *f.eventTime,
+			AllowedLateness: *f.lateness,
+			Clock:           clk,
+		}
+		if *f.span > 0 {
+			config.MaxSize = 0
+			config.TimeWindow = *f.span
+		}
+		if *f.history > 0 {
+			config.Rollup = stream.RollupTiersFor(*f.history)
+		}
+		w := stream.NewWindow(config)
+		var detector *analysis.Detector
+		if method != "" || *f.changePoints {
+			detector = analysis.NewDetector(analysis.Config{
+				Method:       method,
+				K:            *f.anomalyK,
+				Window:       *f.anomalyWindow,
+				ChangePoints: *f.changePoints,
+			})
+		}
+		d.router.AddRoute(&route.Route{
 			Selector:  sel,
-			ChartType: chartType,
+			ChartType: spec.ChartType,
 			Chart:     c,
 			Window:    w,
+			Transform: spec.Transform,
+			Detector:  detector,
 		})
+		d.windows[key] = w
 
-		region := layout.NewRegion(0, i*regionHeight, termWidth, regionHeight)
+		region := layout.NewRegion(0, i*regionHeight, width, regionHeight)
 		region.Chart = c
 		region.Window = w
 		region.Label = key
-		regions = append(regions, region)
+		region.StaleAfter = *f.staleAfter
+		region.NoData = *f.noData
+		d.regions = append(d.regions, region)
 	}
 
-	reader := stream.NewLineReader(ctx, os.Stdin)
-	renderer := layout.NewRenderer(regions)
+	alerts, err := parseAlerts(f.alertRules, d.windows)
+	if err != nil {
+		return nil, err
+	}
+	d.alerts = alerts
+	d.bell = *f.alertBell
+	hooks := alertHooks(f.alertCmds, *f.alertLog)
+	d.engine = alert.NewEngine(alerts, hooks, func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	})
+	return d, nil
+}
 
+// ingest parses a line that arrived at the given time and routes its points,
+// along with any derived points they complete.
+func (d *dashboard) ingest(line source.Line, arrival time.Time) {
+	for _, point := range d.parsers.Parse(line, arrival).Points {
+		d.router.Route(point)
+		if d.deriver != nil {
+			for _, dp := range d.deriver.Add(point) {
+				d.router.Route(dp)
+			}
+		}
+	}
+}
+
+// flush releases points held back for alignment or lateness. Call it when
+// the input ends.
+func (d *dashboard) flush() {
+	if d.deriver != nil {
+		for _, point := range d.deriver.Flush() {
+			d.router.Route(point)
+		}
+	}
+	for _, r := range d.router.Routes() {
+		r.Window.Flush()
+	}
+}
+
+// evaluate checks alert rules and shows active ones on their regions,
+// along with anomaly counts and change points.
+func (d *dashboard) evaluate(now time.Time) {
+	for i, r := range d.router.Routes() {
+		if r.Detector != nil {
+			d.regions[i].Footer = r.Detector.Summary()
+		}
+	}
+	if len(d.alerts) == 0 {
+		return
+	}
+	// Age out quiet windows first, so alerts on them can resolve
+	for _, w := range d.windows {
+		w.Expire()
+	}
+	for _, ev := range d.engine.Evaluate(d.windows, now) {
+		if d.bell && d.render != nil && ev.State == alert.Firing.String() {
+			d.render.Bell()
+		}
+	}
+	for _, region := range d.regions {
+		banner, state := d.engine.Banner(region.Label, now)
+		region.Banner = banner
+		region.BannerColor = map[alert.State]string{alert.Pending: "yellow", alert.Firing: "red"}[state]
+	}
+}
+
+// renderer returns a renderer for the dashboard's regions on its clock,
+// which rings the alert bell from then on.
+func (d *dashboard) renderer() *layout.Renderer {
+	r := layout.NewRenderer(d.regions)
+	r.Clock = d.clock
+	d.render = r
+	return r
+}
+
+// close waits for pending alert hooks.
+func (d *dashboard) close() {
+	d.engine.Close()
+	if n := d.engine.Dropped(); n > 0 {
+		fmt.Fprintf(os.Stderr, "rift: %d alert events dropped while hooks were busy\n", n)
+	}
+}
+
+// Split command: route a single input to multiple charts.
+func runSplit(args []string) error {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	flags := addSplitFlags(fs)
+	exitOnIdle := fs.Duration("exit-on-idle", 0, "exit after this long without input (e.g. 30s)")
+	input := addReaderFlags(fs)
+	fs.Parse(args)
+	return runDashboard(flags, input, *exitOnIdle)
+}
+
+// runDashboard reads the inputs into the dashboard described by flags and
+// redraws it until the input ends, is idle for exitOnIdle, or is interrupted.
+func runDashboard(flags *splitFlags, input *readerFlags, exitOnIdle time.Duration) error {
+	ctx, cancel := setupContext()
+	defer cancel()
+
+	clk := clock.Real
+	reader, err := input.open(ctx, clk)
+	if err != nil {
+		return err
+	}
+
+	// Lines may be discarded under a non-blocking policy, so keep the last
+	// row for a status line counting them
+	termWidth, termHeight, _ := layout.GetTerminalSize()
+	dashHeight := termHeight
+	if reader.Policy() != stream.Block {
+		dashHeight = max(termHeight-1, 1)
+	}
+	dash, err := flags.newDashboard(termWidth, dashHeight, clk)
+	if err != nil {
+		return err
+	}
+	defer dash.close()
+
+	renderer := dash.renderer()
+	draw := func() {
+		renderer.Clear()
+		renderer.Render()
+		if dashHeight < termHeight {
+			layout.MoveCursor(0, dashHeight)
+			fmt.Print(readerStatus(reader))
+		}
+	}
+
+	defer func() {
+		if !reader.Stats().Intact() {
+			fmt.Fprintf(os.Stderr, "rift: %s\n", readerStatus(reader))
+		}
+	}()
 	layout.HideCursor()
 	defer layout.ShowCursor()
 	renderer.Clear()
 
-	ticker := time.NewTicker(100 * time.Millisecond)
+	ticker := clk.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
+	idle := newIdleTimer(exitOnIdle)
+	defer idle.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 
+		case <-idle.C():
+			draw()
+			return nil
+
 		case line, ok := <-reader.Lines():
 			if !ok {
-				renderer.Clear()
-				renderer.Render()
+				dash.flush()
+				dash.evaluate(clk.Now())
+				draw()
 				time.Sleep(2 * time.Second)
 				return nil
 			}
 
-			result := format.AutoParse(line)
-			for _, point := range result.Points {
-				router.Route(point)
-			}
+			idle.Reset()
+			dash.ingest(line, clk.Now())
 
-		case <-ticker.C:
-			renderer.Clear()
-			renderer.Render()
+		case <-ticker.C():
+			dash.evaluate(clk.Now())
+			draw()
 
 		case err := <-reader.Errors():
 			if err != nil {
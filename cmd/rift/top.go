@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/danqzq/rift/internal/source"
+)
+
+// topRoutes is the default top dashboard: host CPU, memory, load, network
+// and disk, all totals over interfaces and disks.
+var topRoutes = []string{
+	"cpu.busy:sparkline",
+	"mem.used_pct:sparkline",
+	"load.1:sparkline",
+	"net.rx_bytes_per_s:sparkline",
+	"net.tx_bytes_per_s:sparkline",
+	"disk.read_bytes_per_s:sparkline",
+	"disk.write_bytes_per_s:sparkline",
+}
+
+// topProcessRoutes are added when tracking a process with --pid.
+var topProcessRoutes = []string{
+	"proc.cpu:sparkline",
+	"proc.rss_bytes:sparkline",
+}
+
+// Top command: a system metrics dashboard read straight from /proc. It takes
+// the split flags, so --route replaces the default layout and --alert,
+// --anomaly and the rest work as usual.
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	flags := addSplitFlags(fs)
+	input := addReaderFlags(fs)
+	interval := fs.Duration("interval", source.DefaultSysInterval, "how often to collect metrics")
+	pid := fs.Int("pid", 0, "also track CPU and memory of this process")
+	fs.Parse(args)
+
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval %s", *interval)
+	}
+	if len(input.sources) == 0 {
+		spec := fmt.Sprintf("sys=sys:%s", *interval)
+		if *pid != 0 {
+			spec += fmt.Sprintf(",pid=%d", *pid)
+		}
+		input.sources = arrayFlags{spec}
+	}
+	if len(flags.routes) == 0 {
+		flags.routes = append(arrayFlags{}, topRoutes...)
+		if *pid != 0 {
+			flags.routes = append(flags.routes, topProcessRoutes...)
+		}
+	}
+	return runDashboard(flags, input, 0)
+}
@@ -2,7 +2,6 @@ module github.com/danqzq/rift
 
 go 1.24.0
 
-require (
-	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
-)
+require golang.org/x/term v0.39.0
+
+require golang.org/x/sys v0.40.0 // indirect
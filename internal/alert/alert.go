@@ -0,0 +1,259 @@
+// Package alert evaluates threshold rules such as "p95(latency) > 300 for 30s"
+// and tracks each rule through pending, firing and resolved states.
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danqzq/rift/internal/expr"
+)
+
+// State is the state of an alert rule.
+type State int
+
+const (
+	// Inactive means the condition is not met.
+	Inactive State = iota
+	// Pending means the condition is met but has not yet held for the rule's duration.
+	Pending
+	// Firing means the condition has held for the rule's duration.
+	Firing
+)
+
+func (s State) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Firing:
+		return "firing"
+	}
+	return "inactive"
+}
+
+// Rule is a parsed alert rule:
+//
+//	expression op threshold [for duration] [clear threshold]
+//
+// where op is one of > >= < <=. A firing rule resolves once the value no
+// longer satisfies op against the clear threshold, which defaults to the
+// threshold itself. Setting it apart (e.g. "> 300 clear 250") adds hysteresis
+// so a value hovering around the threshold does not flap.
+type Rule struct {
+	Text      string
+	Expr      expr.Node
+	Op        string
+	Threshold float64
+	Clear     float64
+	For       time.Duration
+}
+
+// comparisons are the supported operators, longest first so ">=" is found
+// before ">".
+var comparisons = []string{">=", "<=", ">", "<"}
+
+// ParseRule parses a rule such as "p95(latency) > 300 for 30s clear 250".
+func ParseRule(s string) (*Rule, error) {
+	var op string
+	idx := -1
+	for _, c := range comparisons {
+		if i := strings.Index(s, c); i >= 0 && (idx < 0 || i < idx) {
+			op, idx = c, i
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("invalid alert rule %q, expected expression > threshold", s)
+	}
+
+	n, err := expr.Parse(s[:idx])
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert rule %q: %w", s, err)
+	}
+
+	fields := strings.Fields(s[idx+len(op):])
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid alert rule %q, missing threshold", s)
+	}
+	threshold, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %q in alert rule %q", fields[0], s)
+	}
+
+	rule := &Rule{
+		Text:      strings.TrimSpace(s),
+		Expr:      n,
+		Op:        op,
+		Threshold: threshold,
+		Clear:     threshold,
+	}
+
+	rest := fields[1:]
+	for len(rest) > 0 {
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("invalid alert rule %q, %q needs a value", s, rest[0])
+		}
+		switch rest[0] {
+		case "for":
+			d, err := time.ParseDuration(rest[1])
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid duration %q in alert rule %q", rest[1], s)
+			}
+			rule.For = d
+		case "clear":
+			v, err := strconv.ParseFloat(rest[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid clear threshold %q in alert rule %q", rest[1], s)
+			}
+			rule.Clear = v
+		default:
+			return nil, fmt.Errorf("unknown keyword %q in alert rule %q", rest[0], s)
+		}
+		rest = rest[2:]
+	}
+
+	if strings.HasPrefix(op, ">") && rule.Clear > rule.Threshold ||
+		strings.HasPrefix(op, "<") && rule.Clear < rule.Threshold {
+		return nil, fmt.Errorf("clear threshold %g is on the wrong side of %g in alert rule %q", rule.Clear, threshold, s)
+	}
+	return rule, nil
+}
+
+// compare applies the rule's operator to v and a threshold.
+func (r *Rule) compare(v, threshold float64) bool {
+	switch r.Op {
+	case ">":
+		return v > threshold
+	case ">=":
+		return v >= threshold
+	case "<":
+		return v < threshold
+	case "<=":
+		return v <= threshold
+	}
+	return false
+}
+
+// Event describes a state change of an alert.
+type Event struct {
+	Rule      string    `json:"rule"`
+	Key       string    `json:"key,omitempty"`
+	State     string    `json:"state"` // pending, firing or resolved
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Time      time.Time `json:"time"`
+}
+
+// Alert tracks one rule's state.
+type Alert struct {
+	*Rule
+	Key string // route the alert is attached to
+
+	state State
+	since time.Time // when the current state was entered
+	value float64   // last evaluated value
+}
+
+// New creates an inactive alert for a rule attached to the route key.
+func New(key string, rule *Rule) *Alert {
+	return &Alert{Rule: rule, Key: key}
+}
+
+// State returns the current state and when it was entered.
+func (a *Alert) State() (State, time.Time) {
+	return a.state, a.since
+}
+
+// Value returns the most recently evaluated value.
+func (a *Alert) Value() float64 {
+	return a.value
+}
+
+// Evaluate computes the rule at now and advances the state machine. It
+// returns an event when the alert becomes pending, fires or resolves. A
+// pending alert whose condition lapses returns to inactive silently. If the
+// expression cannot be evaluated the state is unchanged, unless none of the
+// series it refers to has data any more, as when a quiet time window
+// empties: then the alert resolves with its last value.
+func (a *Alert) Evaluate(env expr.Env, now time.Time) (Event, bool) {
+	v, ok := a.Expr.Eval(env)
+	if ok {
+		a.value = v
+	} else {
+		v = a.value
+	}
+
+	var next State
+	switch {
+	case !ok:
+		next = a.state
+		if a.noData(env) {
+			next = Inactive
+		}
+	case a.state == Firing:
+		next = Inactive
+		if a.compare(v, a.Clear) {
+			next = Firing
+		}
+	case a.state == Pending:
+		next = Inactive
+		if a.compare(v, a.Threshold) {
+			next = Pending
+			if now.Sub(a.since) >= a.For {
+				next = Firing
+			}
+		}
+	default:
+		if a.compare(v, a.Threshold) {
+			next = Pending
+			if a.For <= 0 {
+				next = Firing
+			}
+		}
+	}
+
+	if next == a.state {
+		return Event{}, false
+	}
+	prev := a.state
+	a.state, a.since = next, now
+
+	state := next.String()
+	switch {
+	case next == Inactive && prev == Firing:
+		state = "resolved"
+	case next == Inactive:
+		return Event{}, false
+	}
+	return Event{
+		Rule:      a.Text,
+		Key:       a.Key,
+		State:     state,
+		Value:     v,
+		Threshold: a.Threshold,
+		Time:      now,
+	}, true
+}
+
+// noData reports whether none of the series the rule refers to has a value.
+func (a *Alert) noData(env expr.Env) bool {
+	for _, ref := range expr.Refs(a.Expr) {
+		if len(env.Values(ref)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Banner returns a one-line description of a pending or firing alert, or ""
+// if it is inactive.
+func (a *Alert) Banner(now time.Time) string {
+	switch a.state {
+	case Firing:
+		return fmt.Sprintf("FIRING %s (%.4g) for %s", a.Text, a.value, now.Sub(a.since).Truncate(time.Second))
+	case Pending:
+		return fmt.Sprintf("pending %s (%.4g)", a.Text, a.value)
+	}
+	return ""
+}
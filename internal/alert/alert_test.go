@@ -0,0 +1,257 @@
+package alert
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danqzq/rift/internal/clock"
+	"github.com/danqzq/rift/internal/expr"
+	"github.com/danqzq/rift/internal/stream"
+)
+
+func TestParseRule(t *testing.T) {
+	r, err := ParseRule("p95(latency) > 300 for 30s clear 250")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Op != ">" || r.Threshold != 300 || r.Clear != 250 || r.For != 30*time.Second {
+		t.Errorf("got op=%s threshold=%v clear=%v for=%v", r.Op, r.Threshold, r.Clear, r.For)
+	}
+
+	r, err = ParseRule("free <= 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Op != "<=" || r.Clear != 10 || r.For != 0 {
+		t.Errorf("got op=%s clear=%v for=%v", r.Op, r.Clear, r.For)
+	}
+
+	for _, s := range []string{
+		"latency 300",
+		"latency >",
+		"latency > abc",
+		"latency > 300 for",
+		"latency > 300 for soon",
+		"latency > 300 until 5s",
+		"latency > 300 clear 400",
+		"free < 10 clear 5",
+		"p95( > 300",
+	} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("ParseRule(%q) should fail", s)
+		}
+	}
+}
+
+func TestAlert_StateMachine(t *testing.T) {
+	rule, err := ParseRule("x > 10 for 2s clear 8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New("x", rule)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		sec   int
+		value float64
+		event string // "" for no event
+		state State
+	}{
+		{0, 5, "", Inactive},
+		{1, 12, "pending", Pending},
+		{2, 9, "", Inactive}, // lapsed before 2s, silently
+		{3, 12, "pending", Pending},
+		{4, 15, "", Pending},
+		{5, 11, "firing", Firing},
+		{6, 9, "", Firing}, // below threshold but above clear
+		{7, 8, "resolved", Inactive},
+	}
+
+	for _, s := range steps {
+		ev, ok := a.Evaluate(expr.Map{"x": s.value}, start.Add(time.Duration(s.sec)*time.Second))
+		got := ""
+		if ok {
+			got = ev.State
+		}
+		if got != s.event {
+			t.Errorf("t=%ds: event %q, want %q", s.sec, got, s.event)
+		}
+		if state, _ := a.State(); state != s.state {
+			t.Errorf("t=%ds: state %v, want %v", s.sec, state, s.state)
+		}
+	}
+
+	// Missing data leaves the state alone
+	if _, ok := a.Evaluate(expr.Map{}, start.Add(8*time.Second)); ok {
+		t.Error("missing data should not produce an event")
+	}
+}
+
+func TestAlert_FiresImmediatelyWithoutFor(t *testing.T) {
+	rule, err := ParseRule("x > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New("x", rule)
+	ev, ok := a.Evaluate(expr.Map{"x": 2}, time.Now())
+	if !ok || ev.State != "firing" || ev.Key != "x" || ev.Value != 2 {
+		t.Errorf("got %+v, %v; want firing event", ev, ok)
+	}
+	if !strings.HasPrefix(a.Banner(time.Now()), "FIRING x > 1") {
+		t.Errorf("banner = %q", a.Banner(time.Now()))
+	}
+}
+
+func TestAlert_ResolvesWhenWindowEmpties(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	w := stream.NewWindow(stream.WindowConfig{TimeWindow: time.Minute, Clock: clk})
+	env := Windows{"latency": w}
+	rule, err := ParseRule("p95(latency) > 300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New("latency", rule)
+
+	w.Add(stream.NewDataPointAt(500, start))
+	if ev, ok := a.Evaluate(env, clk.Now()); !ok || ev.State != "firing" {
+		t.Fatalf("got %+v, %v; want firing event", ev, ok)
+	}
+
+	// The route goes quiet and its window expires empty
+	clk.Advance(2 * time.Minute)
+	w.Expire()
+	ev, ok := a.Evaluate(env, clk.Now())
+	if !ok || ev.State != "resolved" || ev.Value != 500 {
+		t.Errorf("got %+v, %v; want resolved event with the last value", ev, ok)
+	}
+	if state, _ := a.State(); state != Inactive {
+		t.Errorf("state = %v, want inactive", state)
+	}
+}
+
+func TestWindows_Series(t *testing.T) {
+	start := time.Now()
+	w := stream.NewFixedWindow(100)
+	for i := 1; i <= 10; i++ {
+		w.Add(stream.DataPoint{Value: float64(i), Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	env := Windows{"latency": w}
+
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"latency", 10},
+		{"avg(latency)", 5.5},
+		{"max(latency) - min(latency)", 9},
+		{"count(latency)", 10},
+		{"rate(latency)", 55.0 / 9},
+		{"p50(latency)", 5},
+	}
+	for _, tt := range tests {
+		n, err := expr.Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := n.Eval(env)
+		if !ok {
+			t.Errorf("%s: not evaluated", tt.expr)
+			continue
+		}
+		// Quantiles come from a sketch with 1% relative error
+		if d := got - tt.want; d > tt.want*0.02 || d < -tt.want*0.02 {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	if _, ok := env.Series("p95", "missing"); ok {
+		t.Error("unknown series should not evaluate")
+	}
+}
+
+func TestEngine_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "alerts.log")
+	cmdOut := filepath.Join(dir, "cmd.json")
+
+	rule, err := ParseRule("x > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hookErrs []error
+	engine := NewEngine([]*Alert{New("x", rule)}, []Hook{
+		&FileHook{Path: logPath},
+		&CommandHook{Command: "cat >> " + cmdOut},
+	}, func(err error) { hookErrs = append(hookErrs, err) })
+
+	now := time.Now()
+	engine.Evaluate(expr.Map{"x": 5}, now)
+	if banner, state := engine.Banner("x", now); state != Firing || banner == "" {
+		t.Errorf("Banner = %q, %v; want firing banner", banner, state)
+	}
+	engine.Evaluate(expr.Map{"x": 0}, now.Add(time.Second))
+	engine.Close()
+
+	if len(hookErrs) > 0 {
+		t.Fatalf("hook errors: %v", hookErrs)
+	}
+	for _, path := range []string{logPath, cmdOut} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("%s: got %d lines, want 2:\n%s", path, len(lines), data)
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.State != "resolved" || ev.Rule != "x > 1" {
+			t.Errorf("%s: last event %+v, want resolved x > 1", path, ev)
+		}
+	}
+}
+
+// blockingHook holds up the hook goroutine until release is closed.
+type blockingHook struct{ release chan struct{} }
+
+func (h *blockingHook) Notify(Event) error {
+	<-h.release
+	return nil
+}
+
+func TestEngine_DropsWhenHooksAreBusy(t *testing.T) {
+	rule, err := ParseRule("x > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := &blockingHook{release: make(chan struct{})}
+	engine := NewEngine([]*Alert{New("x", rule)}, []Hook{hook}, nil)
+
+	// Each evaluation flips the alert, so every one produces an event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		now := time.Now()
+		for i := 0; i < 200; i++ {
+			engine.Evaluate(expr.Map{"x": float64(5 * (i % 2))}, now.Add(time.Duration(i)*time.Second))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Evaluate blocked on a busy hook")
+	}
+	close(hook.release)
+	engine.Close()
+	if engine.Dropped() == 0 {
+		t.Error("events beyond the queue should be dropped and counted")
+	}
+}
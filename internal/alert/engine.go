@@ -0,0 +1,87 @@
+package alert
+
+import (
+	"time"
+
+	"github.com/danqzq/rift/internal/expr"
+)
+
+// Engine evaluates a set of alerts and passes their events to hooks. Hooks
+// run one at a time on a background goroutine, in event order, so a slow
+// command does not hold up rendering. Events that arrive while the hook
+// queue is full are dropped and counted.
+type Engine struct {
+	alerts  []*Alert
+	hooks   []Hook
+	onError func(error)
+	events  chan Event
+	done    chan struct{}
+	dropped int
+}
+
+// NewEngine creates an engine. onError, if non-nil, receives hook errors.
+func NewEngine(alerts []*Alert, hooks []Hook, onError func(error)) *Engine {
+	e := &Engine{
+		alerts:  alerts,
+		hooks:   hooks,
+		onError: onError,
+		events:  make(chan Event, 64),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *Engine) run() {
+	defer close(e.done)
+	for ev := range e.events {
+		for _, h := range e.hooks {
+			if err := h.Notify(ev); err != nil && e.onError != nil {
+				e.onError(err)
+			}
+		}
+	}
+}
+
+// Evaluate evaluates every alert and queues hooks for any state changes,
+// which are also returned.
+func (e *Engine) Evaluate(env expr.Env, now time.Time) []Event {
+	var events []Event
+	for _, a := range e.alerts {
+		if ev, ok := a.Evaluate(env, now); ok {
+			events = append(events, ev)
+			select {
+			case e.events <- ev:
+			default:
+				e.dropped++
+			}
+		}
+	}
+	return events
+}
+
+// Dropped returns the number of events not passed to hooks because the
+// queue was full.
+func (e *Engine) Dropped() int {
+	return e.dropped
+}
+
+// Banner returns the banner of the most severe active alert attached to a
+// route key, and its state. Firing alerts take precedence over pending ones.
+func (e *Engine) Banner(key string, now time.Time) (string, State) {
+	var banner string
+	state := Inactive
+	for _, a := range e.alerts {
+		if a.Key != key || a.state <= state {
+			continue
+		}
+		banner, state = a.Banner(now), a.state
+	}
+	return banner, state
+}
+
+// Close waits for queued hooks to finish.
+func (e *Engine) Close() {
+	close(e.events)
+	<-e.done
+}
@@ -0,0 +1,78 @@
+package alert
+
+import (
+	"path"
+
+	"github.com/danqzq/rift/internal/expr"
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// Windows is an expr.SeriesEnv that resolves series names to route windows
+// by route key. A bare name evaluates to the window's last value; window
+// functions such as p95(name) are computed over everything in the window.
+type Windows map[string]*stream.Window
+
+// Value implements expr.Env.
+func (ws Windows) Value(name string) (float64, bool) {
+	w, ok := ws[name]
+	if !ok {
+		return 0, false
+	}
+	p, ok := w.Last()
+	return p.Value, ok
+}
+
+// Values implements expr.Env.
+func (ws Windows) Values(pattern string) []float64 {
+	var values []float64
+	for name, w := range ws {
+		if ok, _ := path.Match(pattern, name); !ok {
+			continue
+		}
+		if p, ok := w.Last(); ok {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// Series implements expr.SeriesEnv. rate is the sum of values per second
+// over the time between the window's first and last points, matching the
+// rate aggregation used by charts.
+func (ws Windows) Series(fn, name string) (float64, bool) {
+	w, ok := ws[name]
+	if !ok || w.Len() == 0 {
+		return 0, false
+	}
+
+	if q, ok := expr.Percentile(fn); ok {
+		return w.Quantile(q), true
+	}
+
+	stats := w.Stats()
+	switch fn {
+	case "last":
+		return ws.Value(name)
+	case "avg", "mean":
+		return stats.Mean, true
+	case "min":
+		return stats.Min, true
+	case "max":
+		return stats.Max, true
+	case "sum":
+		return stats.Sum, true
+	case "count":
+		return float64(stats.Count), true
+	case "stddev":
+		return stats.StdDev, true
+	case "rate":
+		first, _ := w.First()
+		last, _ := w.Last()
+		span := last.Timestamp.Sub(first.Timestamp).Seconds()
+		if span <= 0 {
+			return 0, false
+		}
+		return stats.Sum / span, true
+	}
+	return 0, false
+}
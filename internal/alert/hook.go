@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Hook is notified of alert state changes.
+type Hook interface {
+	Notify(e Event) error
+}
+
+// CommandHook runs a shell command with the event as JSON on stdin.
+type CommandHook struct {
+	Command string
+	Timeout time.Duration // defaults to 10s
+}
+
+// Notify implements Hook.
+func (h *CommandHook) Notify(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("alert hook %q: %w: %s", h.Command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// FileHook appends each event to a file as a line of JSON.
+type FileHook struct {
+	Path string
+}
+
+// Notify implements Hook.
+func (h *FileHook) Notify(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
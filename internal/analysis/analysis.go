@@ -0,0 +1,286 @@
+// Package analysis flags anomalous points against a rolling baseline and
+// detects level shifts with CUSUM.
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// Method selects how the rolling baseline band is computed.
+type Method string
+
+const (
+	// ZScore flags values more than K standard deviations from the mean.
+	ZScore Method = "zscore"
+	// MAD flags values more than K scaled median absolute deviations from
+	// the median. It is robust to the outliers it is looking for.
+	MAD Method = "mad"
+)
+
+// ParseMethod parses "zscore" or "mad".
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(s); m {
+	case ZScore, MAD:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid anomaly method %q, expected zscore or mad", s)
+}
+
+// madScale converts a MAD into a standard deviation estimate for normal data.
+const madScale = 1.4826
+
+// Config configures a Detector.
+type Config struct {
+	Method Method  // band method; "" disables point anomalies
+	K      float64 // band width in standard deviations (default 3)
+	Window int     // baseline size in points (default 60)
+
+	// ChangePoints enables CUSUM level-shift detection. Drift is the slack
+	// and Threshold the decision level, both in baseline standard
+	// deviations (defaults 0.5 and 5).
+	ChangePoints bool
+	Drift        float64
+	Threshold    float64
+}
+
+// minBaseline is the number of points needed before anything is flagged.
+const minBaseline = 10
+
+// maxRun bounds the points kept to locate the start of a level shift.
+const maxRun = 1000
+
+// ChangePoint is a detected level shift.
+type ChangePoint struct {
+	Label  string
+	Time   time.Time // when the shift began
+	Before float64   // mean before the shift
+	After  float64   // mean since the shift
+}
+
+// String describes the shift, e.g. "latency stepped up at 14:02:05 (120 → 310)".
+func (c ChangePoint) String() string {
+	dir := "up"
+	if c.After < c.Before {
+		dir = "down"
+	}
+	name := c.Label
+	if name == "" {
+		name = "value"
+	}
+	return fmt.Sprintf("%s stepped %s at %s (%.4g → %.4g)", name, dir, c.Time.Format("15:04:05"), c.Before, c.After)
+}
+
+// Detector tracks a baseline per label, flagging anomalies and recording
+// change points as points are observed.
+type Detector struct {
+	config    Config
+	series    map[string]*series
+	anomalies int
+	changes   []ChangePoint
+}
+
+// NewDetector creates a detector, filling in defaults for unset options.
+func NewDetector(config Config) *Detector {
+	if config.K <= 0 {
+		config.K = 3
+	}
+	if config.Window < minBaseline {
+		config.Window = 60
+	}
+	if config.Drift <= 0 {
+		config.Drift = 0.5
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 5
+	}
+	return &Detector{config: config, series: make(map[string]*series)}
+}
+
+// Observe checks a point against its label's baseline, sets p.Anomaly if it
+// falls outside the band, and adds it to the baseline.
+func (d *Detector) Observe(p stream.DataPoint) stream.DataPoint {
+	s, ok := d.series[p.Label]
+	if !ok {
+		s = &series{values: make([]float64, 0, d.config.Window)}
+		d.series[p.Label] = s
+	}
+
+	if d.config.Method != "" && s.outlier(p.Value, d.config.Method, d.config.K) {
+		p.Anomaly = true
+		d.anomalies++
+	}
+	if d.config.ChangePoints {
+		if cp, ok := s.cusum(p, d.config.Drift, d.config.Threshold); ok {
+			cp.Label = p.Label
+			d.changes = append(d.changes, cp)
+		}
+	}
+	s.add(p.Value, d.config.Window)
+	return p
+}
+
+// Anomalies returns how many points have been flagged.
+func (d *Detector) Anomalies() int {
+	return d.anomalies
+}
+
+// ChangePoints returns the level shifts detected so far, oldest first.
+func (d *Detector) ChangePoints() []ChangePoint {
+	return d.changes
+}
+
+// Summary describes the anomaly count and the latest change point, or ""
+// if nothing has been detected.
+func (d *Detector) Summary() string {
+	var s string
+	if d.anomalies > 0 {
+		s = fmt.Sprintf("%d anomalies", d.anomalies)
+		if d.anomalies == 1 {
+			s = "1 anomaly"
+		}
+	}
+	if n := len(d.changes); n > 0 {
+		if s != "" {
+			s += " · "
+		}
+		s += d.changes[n-1].String()
+	}
+	return s
+}
+
+// series is the rolling baseline and CUSUM state for one label.
+type series struct {
+	values []float64 // ring buffer of recent values
+	next   int
+
+	// CUSUM state, referenced to the baseline when it first filled
+	ref, sigma float64
+	armed      bool
+	pos, neg   float64
+	run        []stream.DataPoint // points since the sums were last zero
+}
+
+func (s *series) add(v float64, size int) {
+	if len(s.values) < size {
+		s.values = append(s.values, v)
+		return
+	}
+	s.values[s.next] = v
+	s.next = (s.next + 1) % size
+}
+
+// outlier reports whether v is outside the baseline band.
+func (s *series) outlier(v float64, method Method, k float64) bool {
+	if len(s.values) < minBaseline {
+		return false
+	}
+
+	var center, spread float64
+	switch method {
+	case MAD:
+		center = median(slices.Clone(s.values))
+		dev := make([]float64, len(s.values))
+		for i, x := range s.values {
+			dev[i] = math.Abs(x - center)
+		}
+		spread = madScale * median(dev)
+	default:
+		center, spread = meanStdDev(s.values)
+	}
+
+	if spread == 0 {
+		return false
+	}
+	return math.Abs(v-center) > k*spread
+}
+
+// cusum updates the two-sided CUSUM with p and reports a level shift once
+// either sum passes the threshold. The baseline reference is then reset so
+// the new level becomes normal.
+func (s *series) cusum(p stream.DataPoint, drift, threshold float64) (ChangePoint, bool) {
+	if !s.armed {
+		if len(s.values) < minBaseline {
+			return ChangePoint{}, false
+		}
+		s.ref, s.sigma = meanStdDev(s.values)
+		s.sigma = math.Max(s.sigma, 1e-9)
+		s.armed = true
+	}
+
+	z := (p.Value - s.ref) / s.sigma
+	s.pos = math.Max(0, s.pos+z-drift)
+	s.neg = math.Max(0, s.neg-z-drift)
+	if s.pos == 0 && s.neg == 0 {
+		s.run = s.run[:0]
+		return ChangePoint{}, false
+	}
+	if len(s.run) >= maxRun {
+		s.run = s.run[1:] // a slow drift keeps the sums up indefinitely
+	}
+	s.run = append(s.run, p)
+
+	if s.pos <= threshold && s.neg <= threshold {
+		return ChangePoint{}, false
+	}
+
+	// Estimate where the shift started as the split of the run that best
+	// explains it as a step away from the reference (the maximum likelihood
+	// split for a mean shift), which is more precise than where the sum
+	// first left zero
+	sign := 1.0
+	if s.neg > threshold {
+		sign = -1
+	}
+	best, bestAt := math.Inf(-1), len(s.run)-1
+	var suffix float64
+	for i := len(s.run) - 1; i >= 0; i-- {
+		suffix += sign * (s.run[i].Value - s.ref)
+		if score := suffix * suffix / float64(len(s.run)-i); suffix > 0 && score > best {
+			best, bestAt = score, i
+		}
+	}
+	var after float64
+	for _, q := range s.run[bestAt:] {
+		after += q.Value
+	}
+	cp := ChangePoint{
+		Time:   s.run[bestAt].Timestamp,
+		Before: s.ref,
+		After:  after / float64(len(s.run)-bestAt),
+	}
+
+	// Start over at the new level
+	s.values = s.values[:0]
+	s.next = 0
+	s.armed = false
+	s.pos, s.neg = 0, 0
+	s.run = s.run[:0]
+	return cp, true
+}
+
+func meanStdDev(values []float64) (mean, stddev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// median sorts values in place and returns the middle value.
+func median(values []float64) float64 {
+	slices.Sort(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+var start = time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+
+// noisy returns n values alternating around level.
+func noisy(level float64, n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = level + float64(i%5) - 2
+	}
+	return values
+}
+
+// observe feeds values one second apart and returns the flagged indexes.
+func observe(d *Detector, label string, values []float64) []int {
+	var flagged []int
+	for i, v := range values {
+		p := d.Observe(stream.DataPoint{Label: label, Value: v, Timestamp: start.Add(time.Duration(i) * time.Second)})
+		if p.Anomaly {
+			flagged = append(flagged, i)
+		}
+	}
+	return flagged
+}
+
+func TestDetector_Outliers(t *testing.T) {
+	for _, method := range []Method{ZScore, MAD} {
+		t.Run(string(method), func(t *testing.T) {
+			values := noisy(100, 40)
+			values[25] = 160
+
+			d := NewDetector(Config{Method: method})
+			flagged := observe(d, "latency", values)
+			if len(flagged) != 1 || flagged[0] != 25 {
+				t.Errorf("flagged %v, want [25]", flagged)
+			}
+			if d.Anomalies() != 1 {
+				t.Errorf("Anomalies() = %d, want 1", d.Anomalies())
+			}
+		})
+	}
+}
+
+func TestDetector_PerLabel(t *testing.T) {
+	d := NewDetector(Config{Method: ZScore})
+	observe(d, "a", noisy(10, 20))
+
+	// A new label has no baseline yet, so its first values are not outliers
+	if flagged := observe(d, "b", []float64{1000}); len(flagged) != 0 {
+		t.Errorf("flagged %v on a new label", flagged)
+	}
+}
+
+func TestDetector_ChangePoint(t *testing.T) {
+	values := append(noisy(120, 30), noisy(310, 30)...)
+
+	d := NewDetector(Config{ChangePoints: true})
+	observe(d, "latency", values)
+
+	changes := d.ChangePoints()
+	if len(changes) != 1 {
+		t.Fatalf("got %d change points %v, want 1", len(changes), changes)
+	}
+	cp := changes[0]
+	if !cp.Time.Equal(start.Add(30 * time.Second)) {
+		t.Errorf("shift at %v, want %v", cp.Time, start.Add(30*time.Second))
+	}
+	if cp.Before < 115 || cp.Before > 125 || cp.After < 300 || cp.After > 320 {
+		t.Errorf("before/after = %v/%v, want about 120/310", cp.Before, cp.After)
+	}
+	if got := d.Summary(); !strings.Contains(got, "latency stepped up at 14:00:30") {
+		t.Errorf("Summary() = %q", got)
+	}
+}
+
+func TestDetector_NoChangeOnStableSeries(t *testing.T) {
+	d := NewDetector(Config{ChangePoints: true})
+	observe(d, "x", noisy(50, 200))
+	if n := len(d.ChangePoints()); n != 0 {
+		t.Errorf("got %d change points on a stable series: %v", n, d.ChangePoints())
+	}
+	if d.Summary() != "" {
+		t.Errorf("Summary() = %q, want empty", d.Summary())
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	if m, err := ParseMethod("mad"); err != nil || m != MAD {
+		t.Errorf("ParseMethod(mad) = %v, %v", m, err)
+	}
+	if _, err := ParseMethod("iqr"); err == nil {
+		t.Error("ParseMethod(iqr) should fail")
+	}
+}
@@ -0,0 +1,100 @@
+package chart
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Aggregation selects how the values of a label are combined into one.
+type Aggregation string
+
+const (
+	AggAvg        Aggregation = "avg"
+	AggSum        Aggregation = "sum"
+	AggCount      Aggregation = "count"
+	AggLast       Aggregation = "last"
+	AggMin        Aggregation = "min"
+	AggMax        Aggregation = "max"
+	AggPercentile Aggregation = "percentile"
+	AggRate       Aggregation = "rate"     // sum of values per second
+	AggDistinct   Aggregation = "distinct" // number of distinct values
+)
+
+// ParseAggregation parses an aggregation name. Percentiles are written as
+// "p95" or "p99.9" and return the quantile (0-1) as q.
+func ParseAggregation(s string) (agg Aggregation, q float64, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch Aggregation(s) {
+	case AggAvg, AggSum, AggCount, AggLast, AggMin, AggMax, AggRate, AggDistinct:
+		return Aggregation(s), 0, nil
+	case "mean", "average":
+		return AggAvg, 0, nil
+	case "median":
+		return AggPercentile, 0.5, nil
+	}
+
+	if strings.HasPrefix(s, "p") {
+		if p, err := strconv.ParseFloat(s[1:], 64); err == nil && p >= 0 && p <= 100 {
+			return AggPercentile, p / 100, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("unknown aggregation %q", s)
+}
+
+// aggregateSeries combines the values of a series into one number.
+// spanSeconds is the time span used for AggRate.
+func aggregateSeries(s *labelSeries, agg Aggregation, q, spanSeconds float64) float64 {
+	values := s.values
+
+	switch agg {
+	case AggSum:
+		return sum(values)
+	case AggCount:
+		return float64(len(values))
+	case AggLast:
+		return values[len(values)-1]
+	case AggMin:
+		return sortedCopy(values)[0]
+	case AggMax:
+		sorted := sortedCopy(values)
+		return sorted[len(sorted)-1]
+	case AggPercentile:
+		return percentile(sortedCopy(values), q)
+	case AggRate:
+		if spanSeconds <= 0 {
+			return 0
+		}
+		return sum(values) / spanSeconds
+	case AggDistinct:
+		seen := make(map[float64]struct{}, len(values))
+		for _, v := range values {
+			seen[v] = struct{}{}
+		}
+		return float64(len(seen))
+	default:
+		return sum(values) / float64(len(values))
+	}
+}
+
+// formatAggregate formats an aggregated value of points in unit, dropping
+// decimals for counts.
+func formatAggregate(agg Aggregation, v float64, unit string) string {
+	switch {
+	case agg == AggCount || agg == AggDistinct:
+		return strconv.Itoa(int(v))
+	case agg == AggRate && unit != "" && !strings.HasSuffix(unit, "/s"):
+		return FormatValue(v, unit) + "/s"
+	}
+	return FormatValue(v, unit)
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
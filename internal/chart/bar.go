@@ -2,6 +2,8 @@ package chart
 
 import (
 	"fmt"
+	"iter"
+	"math"
 	"sort"
 	"strings"
 
@@ -11,14 +13,17 @@ import (
 // Bar renders horizontal bars for categorical comparisons.
 type Bar struct {
 	Config
-	AutoSort bool // if true, sort by value descending
+	AutoSort    bool        // if true, sort by value descending
+	Aggregation Aggregation // how values per label are combined (default avg)
+	Percentile  float64     // quantile (0-1) used by AggPercentile
 }
 
 // NewBar creates a new bar chart.
 func NewBar(config Config) *Bar {
 	return &Bar{
-		Config:   config,
-		AutoSort: true, // default to sorting
+		Config:      config,
+		AutoSort:    true, // default to sorting
+		Aggregation: AggAvg,
 	}
 }
 
@@ -31,18 +36,23 @@ func (b *Bar) Type() string {
 type barEntry struct {
 	label string
 	value float64
-	count int
+	unit  string
 }
 
+// Horizontal eighth blocks, from 1/8 to a full cell.
+var barEighths = []rune{'▏', '▎', '▍', '▌', '▋', '▊', '▉', '█'}
+
 // Render generates horizontal bar visualization.
 func (b *Bar) Render(w *stream.Window, width, height int) string {
-	points := w.Points()
-	if len(points) == 0 {
+	first, ok := w.First()
+	if !ok {
 		return ""
 	}
+	last, _ := w.Last()
 
 	// Aggregate values by label
-	entries := b.aggregate(points)
+	span := last.Timestamp.Sub(first.Timestamp).Seconds()
+	entries := b.aggregate(w.All(), span)
 
 	// Sort if configured
 	if b.AutoSort {
@@ -56,14 +66,16 @@ func (b *Bar) Render(w *stream.Window, width, height int) string {
 		entries = entries[:height]
 	}
 
-	// Find max value for scaling
-	maxVal := 0.0
+	// Find the scale, always including zero unless overridden
+	minVal, maxVal := 0.0, 0.0
 	for _, e := range entries {
-		if e.value > maxVal {
-			maxVal = e.value
-		}
+		minVal = math.Min(minVal, e.value)
+		maxVal = math.Max(maxVal, e.value)
 	}
 
+	if b.Min != nil {
+		minVal = *b.Min
+	}
 	if b.Max != nil {
 		maxVal = *b.Max
 	}
@@ -83,55 +95,86 @@ func (b *Bar) Render(w *stream.Window, width, height int) string {
 		barWidth = 1
 	}
 
-	for _, e := range entries {
-		// Calculate bar length
-		barLen := 0
-		if maxVal > 0 {
-			barLen = int((e.value / maxVal) * float64(barWidth))
-		}
-		if barLen < 0 {
-			barLen = 0
-		}
+	// Cells reserved left of the zero axis for negative bars
+	negWidth := 0
+	if minVal < 0 && maxVal > minVal {
+		negWidth = int(math.Round(-minVal / (maxVal - minVal) * float64(barWidth)))
+		negWidth = min(negWidth, barWidth)
+	}
+	posWidth := barWidth - negWidth
 
+	for _, e := range entries {
 		// Render: "label  █████ value"
 		label := e.label
 		if label == "" {
 			label = "?"
 		}
-		bar := strings.Repeat("█", barLen)
-		sb.WriteString(fmt.Sprintf("%-*s %s %.2f\n", maxLabelLen, label, bar, e.value))
+
+		var bar string
+		if negWidth > 0 {
+			neg := 0.0
+			if e.value < 0 {
+				neg = math.Max(e.value, minVal) / minVal * float64(negWidth)
+			}
+			bar = negativeBar(neg, negWidth) + "│"
+		}
+		if lo := math.Max(minVal, 0); maxVal > lo && e.value > lo {
+			bar += positiveBar((e.value-lo)/(maxVal-lo)*float64(posWidth), posWidth)
+		}
+
+		sb.WriteString(fmt.Sprintf("%-*s %s %s\n", maxLabelLen, label, bar, formatAggregate(b.Aggregation, e.value, e.unit)))
 	}
 
 	return strings.TrimSuffix(sb.String(), "\n")
 }
 
-// aggregate combines points by label, computing average for each.
-func (b *Bar) aggregate(points []stream.DataPoint) []barEntry {
-	m := make(map[string]*barEntry)
+// positiveBar draws a left-aligned bar of the given length in cells, using
+// eighth blocks for the fractional part.
+func positiveBar(length float64, maxCells int) string {
+	length = math.Max(0, math.Min(length, float64(maxCells)))
+	eighths := int(math.Round(length * 8))
 
-	for _, p := range points {
-		label := p.Label
-		if label == "" {
-			label = "value"
-		}
+	bar := strings.Repeat("█", eighths/8)
+	if rem := eighths % 8; rem > 0 {
+		bar += string(barEighths[rem-1])
+	}
+	return bar
+}
 
-		if entry, exists := m[label]; exists {
-			entry.value += p.Value
-			entry.count++
-		} else {
-			m[label] = &barEntry{
-				label: label,
-				value: p.Value,
-				count: 1,
-			}
-		}
+// negativeBar draws a right-aligned bar of the given length, padded to cells.
+// Unicode only has right-aligned half and eighth blocks, so the fractional
+// part is rounded to one of those.
+func negativeBar(length float64, cells int) string {
+	length = math.Max(0, math.Min(length, float64(cells)))
+	eighths := int(math.Round(length * 8))
+	full := eighths / 8
+
+	partial := ""
+	switch rem := eighths % 8; {
+	case rem >= 4:
+		partial = "▐"
+	case rem >= 1:
+		partial = "▕"
 	}
 
-	// Convert to slice and compute averages
-	result := make([]barEntry, 0, len(m))
-	for _, entry := range m {
-		entry.value /= float64(entry.count)
-		result = append(result, *entry)
+	used := full
+	if partial != "" {
+		used++
+	}
+	return strings.Repeat(" ", cells-used) + partial + strings.Repeat("█", full)
+}
+
+// aggregate combines points by label using the configured aggregation.
+// span is the time covered by the points in seconds, used by AggRate.
+func (b *Bar) aggregate(points iter.Seq[stream.DataPoint], span float64) []barEntry {
+	series := groupByLabel(points)
+	result := make([]barEntry, 0, len(series))
+	for _, s := range series {
+		result = append(result, barEntry{
+			label: s.label,
+			value: aggregateSeries(s, b.Aggregation, b.Percentile, span),
+			unit:  s.unit,
+		})
 	}
 
 	return result
@@ -1,8 +1,10 @@
 package chart
 
 import (
+	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/danqzq/rift/internal/stream"
 )
@@ -102,3 +104,449 @@ func TestCounter_Render(t *testing.T) {
 		t.Errorf("result should contain label, got: %s", result)
 	}
 }
+
+func TestCounter_RenderBigDigits(t *testing.T) {
+	w := stream.NewFixedWindow(10)
+	w.Add(stream.NewDataPoint(40))
+	w.Add(stream.NewDataPoint(42))
+
+	c := NewCounter(Config{Label: "Total"})
+	result := c.Render(w, 80, 20)
+	lines := strings.Split(result, "\n")
+
+	if lines[0] != "Total" {
+		t.Errorf("first line should be the label, got: %q", lines[0])
+	}
+	if len(lines) > 20 {
+		t.Errorf("got %d lines, want at most 20", len(lines))
+	}
+	if !strings.Contains(result, "█") {
+		t.Errorf("expected block-font digits, got:\n%s", result)
+	}
+	if !strings.Contains(lines[len(lines)-1], "+2.00") {
+		t.Errorf("last line should show the delta, got: %q", lines[len(lines)-1])
+	}
+	for i, line := range lines {
+		if n := len([]rune(line)); n > 80 {
+			t.Errorf("line %d is %d cells wide, want at most 80", i, n)
+		}
+	}
+}
+
+func TestCounter_RenderBigDigitsWithUnit(t *testing.T) {
+	w := stream.NewFixedWindow(10)
+	p := stream.NewDataPoint(0.25)
+	p.Unit = "s"
+	w.Add(p)
+
+	c := NewCounter(Config{})
+	c.ShowDelta = false
+	result := c.Render(w, 80, 20)
+	lines := strings.Split(strings.TrimRight(result, "\n"), "\n")
+
+	if !strings.Contains(result, "█") {
+		t.Fatalf("expected block-font digits, got:\n%s", result)
+	}
+	if last := lines[len(lines)-1]; !strings.HasSuffix(last, " ms") {
+		t.Errorf("bottom row should end with the unit, got: %q", last)
+	}
+	for i, line := range lines {
+		if n := len([]rune(line)); n > 80 {
+			t.Errorf("line %d is %d cells wide, want at most 80", i, n)
+		}
+	}
+
+	// Compound durations have no number to draw on its own
+	p = stream.NewDataPoint(5400)
+	p.Unit = "s"
+	w.Add(p)
+	if result := c.Render(w, 80, 20); result != "1h30m0s" {
+		t.Errorf("expected plain text for a compound duration, got: %q", result)
+	}
+}
+
+func TestCounter_RenderFallback(t *testing.T) {
+	w := stream.NewFixedWindow(10)
+	w.Add(stream.NewDataPoint(1234.5))
+
+	c := NewCounter(Config{})
+	result := c.Render(w, 10, 3)
+
+	if result != "1234.50" {
+		t.Errorf("expected plain text when space is tight, got: %q", result)
+	}
+}
+
+func TestCounter_RateUsesTimestamps(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := stream.NewFixedWindow(10)
+	for i, v := range []float64{100, 130} {
+		p := stream.NewDataPoint(v)
+		p.Timestamp = start.Add(time.Duration(i) * 10 * time.Second)
+		w.Add(p)
+	}
+
+	c := NewCounter(Config{})
+	c.ShowRate = true
+	c.BigDigits = false
+
+	// Rendering repeatedly must not change the rate
+	for i := 0; i < 2; i++ {
+		if result := c.Render(w, 40, 2); !strings.Contains(result, "(3.0/s)") {
+			t.Errorf("render %d: expected rate 3.0/s, got: %q", i, result)
+		}
+	}
+}
+
+func TestTable_Render(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	for _, v := range []float64{10, 20, 30} {
+		w.Add(stream.NewLabeledDataPoint("api", v))
+	}
+	w.Add(stream.NewLabeledDataPoint("db", 100))
+
+	tbl := NewTable(Config{})
+	tbl.Columns = []string{"last", "max", "count"}
+	tbl.SortBy, tbl.Desc = "max", true
+	result := tbl.Render(w, 80, 10)
+
+	lines := strings.Split(result, "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (header + 2 rows):\n%s", len(lines), result)
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "label last max count" {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "db 100.00 100.00 1" {
+		t.Errorf("first row should be db (highest max), got: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "api 30.00 30.00 3" {
+		t.Errorf("unexpected api row: %q", lines[2])
+	}
+}
+
+func TestTable_RenderUnits(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	for _, v := range []float64{0.9, 1.2} {
+		p := stream.NewLabeledDataPoint("latency", v)
+		p.Unit = "s"
+		w.Add(p)
+	}
+
+	tbl := NewTable(Config{})
+	tbl.Columns = []string{"min", "max", "count"}
+	lines := strings.Split(tbl.Render(w, 80, 10), "\n")
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "latency 900ms 1.2s 2" {
+		t.Errorf("unexpected latency row: %q", lines[1])
+	}
+}
+
+func TestTable_RenderNonFinite(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	for _, v := range []float64{1, 2, math.Inf(1), math.NaN(), math.Inf(-1), 3} {
+		w.Add(stream.NewLabeledDataPoint("x", v))
+	}
+
+	tbl := NewTable(Config{})
+	tbl.Columns = []string{"count", "spark"}
+	lines := strings.Split(tbl.Render(w, 80, 10), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), lines)
+	}
+	if !strings.Contains(lines[1], "▁▄   █") {
+		t.Errorf("spark should scale to the finite values and leave the rest blank, got: %q", lines[1])
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		v    float64
+		unit string
+		want string
+	}{
+		{45.2, "", "45.20"},
+		{0, "s", "0s"},
+		{0.0452, "s", "45.2ms"},
+		{1.2, "s", "1.2s"},
+		{250e-9, "s", "250ns"},
+		{3e-6, "s", "3µs"},
+		{90, "s", "1m30s"},
+		{-0.5, "s", "-500ms"},
+		{512, "B", "512B"},
+		{512 * 1024, "B", "512KiB"},
+		{3.2e9, "B", "2.98GiB"},
+		{2048, "B/s", "2KiB/s"},
+		{45, "%", "45%"},
+		{7, "widgets", "7.00"},
+	}
+	for _, tt := range tests {
+		if got := FormatValue(tt.v, tt.unit); got != tt.want {
+			t.Errorf("FormatValue(%v, %q) = %q, want %q", tt.v, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	cols, err := ParseColumns("p95, last,spark")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(cols, ",") != "p95,last,spark" {
+		t.Errorf("got %v", cols)
+	}
+
+	if _, err := ParseColumns("p95,bogus"); err == nil {
+		t.Error("expected error for unknown column")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0, 1},
+		{0.5, 3},
+		{0.25, 2},
+		{0.9, 4.6},
+		{1, 5},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.q); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestBar_Aggregation(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	w.Add(stream.NewLabeledDataPoint("/api", 1))
+	w.Add(stream.NewLabeledDataPoint("/api", 1))
+	w.Add(stream.NewLabeledDataPoint("/api", 1))
+	w.Add(stream.NewLabeledDataPoint("/health", 2))
+
+	tests := []struct {
+		agg   string
+		first string
+	}{
+		{"sum", "/api 3.00"},
+		{"count", "/api 3"},
+		{"avg", "/health 2.00"},
+		{"max", "/health 2.00"},
+		{"distinct", "/api 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agg, func(t *testing.T) {
+			agg, q, err := ParseAggregation(tt.agg)
+			if err != nil {
+				t.Fatalf("ParseAggregation(%q): %v", tt.agg, err)
+			}
+			b := NewBar(Config{})
+			b.Aggregation, b.Percentile = agg, q
+
+			lines := strings.Split(b.Render(w, 50, 10), "\n")
+			fields := strings.Fields(lines[0])
+			got := fields[0] + " " + fields[len(fields)-1]
+			if got != tt.first {
+				t.Errorf("first line = %q, want %q", got, tt.first)
+			}
+		})
+	}
+}
+
+func TestParseAggregation_Percentile(t *testing.T) {
+	agg, q, err := ParseAggregation("p95")
+	if err != nil || agg != AggPercentile || q != 0.95 {
+		t.Errorf("ParseAggregation(p95) = %v, %v, %v", agg, q, err)
+	}
+
+	if _, _, err := ParseAggregation("p101"); err == nil {
+		t.Error("expected error for p101")
+	}
+}
+
+func TestBar_NegativeValues(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	w.Add(stream.NewLabeledDataPoint("up", 10))
+	w.Add(stream.NewLabeledDataPoint("down", -10))
+
+	b := NewBar(Config{})
+	lines := strings.Split(b.Render(w, 30, 10), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	// Both rows share a zero axis at the same column
+	axisUp := strings.IndexRune(lines[0], '│')
+	axisDown := strings.IndexRune(lines[1], '│')
+	if axisUp < 0 || len([]rune(lines[0][:axisUp])) != len([]rune(lines[1][:axisDown])) {
+		t.Errorf("zero axis not aligned:\n%s\n%s", lines[0], lines[1])
+	}
+	if !strings.Contains(lines[1][:axisDown], "█") {
+		t.Errorf("negative bar should extend left of the axis: %q", lines[1])
+	}
+}
+
+func TestPositiveBar_Fractional(t *testing.T) {
+	if got := positiveBar(2.5, 10); got != "██▌" {
+		t.Errorf("positiveBar(2.5) = %q, want %q", got, "██▌")
+	}
+	if got := positiveBar(0.125, 10); got != "▏" {
+		t.Errorf("positiveBar(0.125) = %q, want %q", got, "▏")
+	}
+}
+
+func TestColumn_Render(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// 1 event in the first bucket, 4 in the third
+	times := []time.Duration{0, 20 * time.Second, 21 * time.Second, 22 * time.Second, 23 * time.Second}
+	for _, d := range times {
+		p := stream.NewDataPoint(1)
+		p.Timestamp = base.Add(d)
+		w.Add(p)
+	}
+
+	c := NewColumn(Config{})
+	c.Bucket = 10 * time.Second
+	result := c.Render(w, 20, 5)
+	lines := strings.Split(result, "\n")
+
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines, want 5:\n%s", len(lines), result)
+	}
+	if !strings.HasPrefix(lines[0], "4 ") {
+		t.Errorf("top line should show the scale, got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[0], "█") {
+		t.Errorf("tallest column should reach the top row, got %q", lines[0])
+	}
+	if got := []rune(lines[3]); got[len(got)-3] != '█' || got[len(got)-2] != ' ' {
+		t.Errorf("bottom row should show first and last buckets with a gap, got %q", lines[3])
+	}
+	if !strings.Contains(lines[4], "count per 10s") {
+		t.Errorf("legend should describe the bucket, got %q", lines[4])
+	}
+}
+
+func TestStacked_Legend(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	for _, l := range []string{"5xx", "2xx", "2xx", "4xx"} {
+		w.Add(stream.NewLabeledDataPoint(l, 1))
+	}
+
+	c := NewStacked(Config{})
+	result := c.Render(w, 30, 6)
+	lines := strings.Split(result, "\n")
+	legend := lines[len(lines)-1]
+
+	i2, i4, i5 := strings.Index(legend, "2xx"), strings.Index(legend, "4xx"), strings.Index(legend, "5xx")
+	if i2 < 0 || i4 < i2 || i5 < i4 {
+		t.Errorf("legend should list labels in order, got %q", legend)
+	}
+	if c.Type() != "stacked" {
+		t.Errorf("Type() = %q, want stacked", c.Type())
+	}
+}
+
+func TestSparkline_TimeMode(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	add := func(offset time.Duration, v float64) {
+		p := stream.NewDataPoint(v)
+		p.Timestamp = base.Add(offset)
+		w.Add(p)
+	}
+
+	// A burst at the start, a gap, then one point at the end
+	add(0, 1)
+	add(100*time.Millisecond, 8)
+	add(200*time.Millisecond, 1)
+	add(10*time.Second, 8)
+
+	s := NewSparkline(Config{})
+	s.TimeMode = true
+	s.Aggregation = AggMax
+	result := []rune(s.Render(w, 10, 1))
+
+	if len(result) != 10 {
+		t.Fatalf("got %d columns, want 10: %q", len(result), string(result))
+	}
+	if result[0] != '█' {
+		t.Errorf("burst column should keep its max, got %q", result[0])
+	}
+	for i := 1; i < 9; i++ {
+		if result[i] != ' ' {
+			t.Errorf("column %d should be an empty gap, got %q", i, result[i])
+		}
+	}
+	if result[9] != '█' {
+		t.Errorf("last column should hold the newest point, got %q", result[9])
+	}
+}
+
+func TestSparkline_TimeModeScalesAggregates(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Five points in the first column, one in the last
+	for _, offset := range []time.Duration{0, 1, 2, 3, 4, 9000} {
+		p := stream.NewDataPoint(1)
+		p.Timestamp = base.Add(offset * time.Millisecond)
+		w.Add(p)
+	}
+
+	for _, agg := range []Aggregation{AggSum, AggCount, AggRate} {
+		s := NewSparkline(Config{})
+		s.TimeMode = true
+		s.Aggregation = agg
+		result := []rune(s.Render(w, 10, 1))
+		if len(result) != 10 {
+			t.Fatalf("%s: got %d columns, want 10: %q", agg, len(result), string(result))
+		}
+		if result[0] != '█' {
+			t.Errorf("%s: busiest column should reach the top, got %q", agg, result[0])
+		}
+		if result[9] == '█' || result[9] == ' ' {
+			t.Errorf("%s: last column should be below the top, got %q", agg, result[9])
+		}
+	}
+}
+
+func TestSparkline_Band(t *testing.T) {
+	w := stream.NewFixedWindow(100)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, v := range []float64{0, 10, 5, 5} {
+		p := stream.NewDataPoint(v)
+		p.Timestamp = base.Add(time.Duration(i) * time.Millisecond)
+		w.Add(p)
+	}
+
+	s := NewSparkline(Config{})
+	s.TimeMode = true
+	s.Band = true
+	lines := strings.Split(s.Render(w, 1, 3), "\n")
+
+	// One column holding everything: avg 5 in the middle, band to both ends
+	want := []string{"░", "█", "░"}
+	for i, line := range lines {
+		if line != want[i] {
+			t.Errorf("row %d = %q, want %q", i, line, want[i])
+		}
+	}
+}
+
+func TestSparkline_MarksAnomalies(t *testing.T) {
+	w := stream.NewFixedWindow(10)
+	for i, v := range []float64{1, 2, 9, 3} {
+		p := stream.NewDataPoint(v)
+		p.Anomaly = i == 2
+		w.Add(p)
+	}
+
+	result := NewSparkline(Config{}).Render(w, 10, 1)
+	if want := "▁▁" + colorize("█", "red") + "▂"; result != want {
+		t.Errorf("got %q, want %q", result, want)
+	}
+}
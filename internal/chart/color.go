@@ -0,0 +1,43 @@
+package chart
+
+import "fmt"
+
+// ANSI color numbers for the names accepted in Config.Color.
+var colorCodes = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// seriesPalette assigns colors to series in order.
+var seriesPalette = []string{"green", "yellow", "red", "blue", "magenta", "cyan", "white"}
+
+const colorReset = "\033[0m"
+
+// colorize wraps s in the foreground color with the given name.
+// Unknown or empty names return s unchanged.
+func colorize(s, color string) string {
+	code, ok := colorCodes[color]
+	if !ok {
+		return s
+	}
+	return fmt.Sprintf("\033[3%dm%s%s", code, s, colorReset)
+}
+
+// colorizeBg wraps s in a foreground and background color.
+func colorizeBg(s, fg, bg string) string {
+	bgCode, ok := colorCodes[bg]
+	if !ok {
+		return colorize(s, fg)
+	}
+	fgCode, ok := colorCodes[fg]
+	if !ok {
+		return fmt.Sprintf("\033[4%dm%s%s", bgCode, s, colorReset)
+	}
+	return fmt.Sprintf("\033[3%d;4%dm%s%s", fgCode, bgCode, s, colorReset)
+}
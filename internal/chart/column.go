@@ -0,0 +1,270 @@
+package chart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// Column renders vertical columns of values bucketed over time, optionally
+// stacked by label.
+type Column struct {
+	Config
+	Bucket      time.Duration // bucket size per column (0 picks one to fit the width)
+	Aggregation Aggregation   // how values per bucket are combined (default count)
+	Percentile  float64       // quantile (0-1) used by AggPercentile
+	Stacked     bool          // if true, split each column by label
+}
+
+// NewColumn creates a new column chart counting events per bucket.
+func NewColumn(config Config) *Column {
+	return &Column{
+		Config:      config,
+		Aggregation: AggCount,
+	}
+}
+
+// NewStacked creates a column chart with each column split by label.
+func NewStacked(config Config) *Column {
+	c := NewColumn(config)
+	c.Stacked = true
+	return c
+}
+
+// Type returns "column" or "stacked".
+func (c *Column) Type() string {
+	if c.Stacked {
+		return "stacked"
+	}
+	return "column"
+}
+
+// Vertical eighth blocks, from 1/8 to a full cell.
+var columnEighths = []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+
+// Bucket sizes tried when Bucket is 0, smallest first.
+var niceBuckets = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// Render generates the column chart with a legend on the last line.
+func (c *Column) Render(w *stream.Window, width, height int) string {
+	first, ok := w.First()
+	if !ok || width < 3 || height < 2 {
+		return ""
+	}
+	last, _ := w.Last()
+
+	// Series in a stable order so colors don't jump between renders
+	var labels []string
+	if c.Stacked {
+		for _, s := range groupByLabel(w.All()) {
+			labels = append(labels, s.label)
+		}
+		sort.Strings(labels)
+	} else {
+		labels = []string{""}
+	}
+
+	plotHeight := height - 1 // legend takes the last line
+	columns, bucket := c.bucketize(w, first.Timestamp, last.Timestamp, labels, width)
+
+	// Scale to the tallest column unless a max is configured
+	maxVal := 0.0
+	for _, col := range columns {
+		maxVal = math.Max(maxVal, sum(col))
+	}
+	if c.Max != nil {
+		maxVal = *c.Max
+	}
+
+	// Y-axis gutter holds the scale
+	top := formatAggregate(c.Aggregation, maxVal, last.Unit)
+	gutter := len(top) + 1
+	plotWidth := width - gutter
+	if plotWidth < 1 {
+		return ""
+	}
+	if len(columns) > plotWidth {
+		columns = columns[len(columns)-plotWidth:]
+	}
+
+	// Stack boundaries per column, in eighths of a cell
+	levels := plotHeight * 8
+	bounds := make([][]int, len(columns))
+	for i, col := range columns {
+		bounds[i] = make([]int, len(col))
+		total := 0.0
+		for j, v := range col {
+			total += math.Max(v, 0)
+			h := 0
+			if maxVal > 0 {
+				h = int(math.Round(math.Min(total/maxVal, 1) * float64(levels)))
+			}
+			bounds[i][j] = h
+		}
+	}
+
+	var sb strings.Builder
+	for row := plotHeight - 1; row >= 0; row-- {
+		switch row {
+		case plotHeight - 1:
+			sb.WriteString(fmt.Sprintf("%*s ", gutter-1, top))
+		case 0:
+			sb.WriteString(fmt.Sprintf("%*s ", gutter-1, "0"))
+		default:
+			sb.WriteString(strings.Repeat(" ", gutter))
+		}
+
+		// Right-align so the newest bucket is at the right edge
+		sb.WriteString(strings.Repeat(" ", plotWidth-len(columns)))
+		for i := range columns {
+			sb.WriteString(c.cell(bounds[i], row))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(c.legend(labels, bucket))
+	return sb.String()
+}
+
+// bucketize groups points into time buckets and aggregates each series per
+// bucket. The result has one entry per bucket, oldest first, with one value
+// per label, along with the bucket size used.
+func (c *Column) bucketize(w *stream.Window, first, last time.Time, labels []string, width int) ([][]float64, time.Duration) {
+	bucket := c.Bucket
+	if bucket <= 0 {
+		bucket = pickBucket(last.Sub(first), width)
+	}
+
+	// Only the buckets that can be shown are kept
+	start := first.Truncate(bucket)
+	end := last.Truncate(bucket)
+	if earliest := end.Add(-time.Duration(width-1) * bucket); start.Before(earliest) {
+		start = earliest
+	}
+	n := int(end.Sub(start)/bucket) + 1
+
+	labelIndex := make(map[string]int, len(labels))
+	for i, l := range labels {
+		labelIndex[l] = i
+	}
+
+	// Collect each series per bucket, then aggregate
+	series := make([][]*labelSeries, n)
+	for p := range w.All() {
+		b := int(p.Timestamp.Truncate(bucket).Sub(start) / bucket)
+		if b < 0 || b >= n {
+			continue
+		}
+
+		li := 0
+		if c.Stacked {
+			label := p.Label
+			if label == "" {
+				label = "value"
+			}
+			var exists bool
+			if li, exists = labelIndex[label]; !exists {
+				continue // label arrived after the legend was built
+			}
+		}
+
+		if series[b] == nil {
+			series[b] = make([]*labelSeries, len(labels))
+		}
+		if series[b][li] == nil {
+			series[b][li] = &labelSeries{}
+		}
+		series[b][li].values = append(series[b][li].values, p.Value)
+	}
+
+	result := make([][]float64, n)
+	for b := range series {
+		result[b] = make([]float64, len(labels))
+		for li, s := range series[b] {
+			if s != nil {
+				result[b][li] = aggregateSeries(s, c.Aggregation, c.Percentile, bucket.Seconds())
+			}
+		}
+	}
+
+	return result, bucket
+}
+
+// pickBucket returns the smallest nice bucket that fits span into width columns.
+func pickBucket(span time.Duration, width int) time.Duration {
+	for _, b := range niceBuckets {
+		if span/b < time.Duration(width) {
+			return b
+		}
+	}
+	return niceBuckets[len(niceBuckets)-1]
+}
+
+// cell draws one row of a column given its stack boundaries in eighths.
+// Each cell can show two series: the one at its bottom as the foreground
+// and the one at its top as the background.
+func (c *Column) cell(bounds []int, row int) string {
+	base := row * 8
+
+	seriesAt := func(pos int) int {
+		for i, b := range bounds {
+			if pos < b {
+				return i
+			}
+		}
+		return -1
+	}
+
+	bottom := seriesAt(base)
+	if bottom < 0 {
+		return " "
+	}
+	topSeries := seriesAt(base + 7)
+
+	filled := min(bounds[bottom]-base, 8)
+	char := string(columnEighths[filled-1])
+
+	if !c.Stacked {
+		return colorize(char, c.Color)
+	}
+	if topSeries == bottom || topSeries < 0 {
+		return colorize(char, seriesColor(bottom))
+	}
+	return colorizeBg(char, seriesColor(bottom), seriesColor(topSeries))
+}
+
+// legend describes the series and bucket size.
+func (c *Column) legend(labels []string, bucket time.Duration) string {
+	var parts []string
+
+	if c.Stacked {
+		for i, l := range labels {
+			parts = append(parts, colorize("■", seriesColor(i))+" "+l)
+		}
+	} else if c.Label != "" {
+		parts = append(parts, colorize("■", c.Color)+" "+c.Label)
+	}
+
+	agg := string(c.Aggregation)
+	if c.Aggregation == AggPercentile {
+		agg = fmt.Sprintf("p%g", c.Percentile*100)
+	}
+	parts = append(parts, fmt.Sprintf("%s per %s", agg, bucket))
+
+	return strings.Join(parts, "  ")
+}
+
+func seriesColor(i int) string {
+	return seriesPalette[i%len(seriesPalette)]
+}
@@ -2,7 +2,7 @@ package chart
 
 import (
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/danqzq/rift/internal/stream"
 )
@@ -10,16 +10,18 @@ import (
 // Counter renders a single large numeric value.
 type Counter struct {
 	Config
-	ShowRate bool // if true, compute and show events per second
-	lastVal  float64
-	lastTime time.Time
+	ShowRate  bool // if true, show the per-second change between the last two points
+	ShowDelta bool // if true, show the change from the previous point
+	BigDigits bool // if true, draw the value in block-font digits when space allows
 }
 
 // NewCounter creates a new counter chart.
 func NewCounter(config Config) *Counter {
 	return &Counter{
-		Config:   config,
-		ShowRate: false,
+		Config:    config,
+		ShowRate:  false,
+		ShowDelta: true,
+		BigDigits: true,
 	}
 }
 
@@ -36,27 +38,149 @@ func (c *Counter) Render(w *stream.Window, width, height int) string {
 	}
 
 	value := last.Value
+	valueStr := FormatValue(value, last.Unit)
+	detail := c.detail(w, value, last.Unit)
 
-	var rate float64
-	var rateStr string
-	if c.ShowRate {
-		now := time.Now()
-		if !c.lastTime.IsZero() {
-			elapsed := now.Sub(c.lastTime).Seconds()
-			if elapsed > 0 {
-				rate = (value - c.lastVal) / elapsed
-				rateStr = fmt.Sprintf(" (%.1f/s)", rate)
+	if c.BigDigits {
+		if big, ok := c.renderBig(valueStr, detail, width, height); ok {
+			return big
+		}
+	}
+
+	line := valueStr
+	if c.Label != "" {
+		line = fmt.Sprintf("%s: %s", c.Label, valueStr)
+	}
+
+	if detail == "" {
+		return line
+	}
+	if height > 1 {
+		return line + "\n" + detail
+	}
+	return line + " " + detail
+}
+
+// detail builds the delta/rate line shown underneath the value.
+func (c *Counter) detail(w *stream.Window, value float64, unit string) string {
+	var parts []string
+
+	var recent []stream.DataPoint
+	for p := range w.Tail(2) {
+		recent = append(recent, p)
+	}
+
+	if c.ShowDelta && len(recent) > 1 {
+		delta := value - recent[0].Value
+		arrow := "="
+		if delta > 0 {
+			arrow = "▲"
+		} else if delta < 0 {
+			arrow = "▼"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", arrow, signed(delta, unit)))
+	}
+
+	// Rate between the last two points, using their timestamps rather than
+	// render time so it does not depend on how often the screen refreshes
+	if c.ShowRate && len(recent) > 1 {
+		elapsed := recent[1].Timestamp.Sub(recent[0].Timestamp).Seconds()
+		if elapsed > 0 {
+			rate := (value - recent[0].Value) / elapsed
+			if unit == "" {
+				parts = append(parts, fmt.Sprintf("(%.1f/s)", rate))
+			} else {
+				parts = append(parts, fmt.Sprintf("(%s/s)", FormatValue(rate, unit)))
 			}
 		}
-		c.lastVal = value
-		c.lastTime = now
 	}
 
-	valueStr := fmt.Sprintf("%.2f", value)
+	return strings.Join(parts, " ")
+}
 
+// renderBig draws the value in the block font, scaled to fill the region,
+// with any unit in plain text after its bottom row. Returns false if the
+// region is too small or the value cannot be drawn.
+func (c *Counter) renderBig(valueStr, detail string, width, height int) (string, bool) {
+	number, unit := splitUnit(valueStr)
+	textWidth, ok := bigTextWidth(number)
+	if !ok || number == "" {
+		return "", false
+	}
+	unitWidth := 0
+	if unit != "" {
+		unitWidth = 1 + len([]rune(unit))
+	}
+
+	// Reserve rows for the label above and the detail line below
+	avail := height
 	if c.Label != "" {
-		return fmt.Sprintf("%s: %s%s", c.Label, valueStr, rateStr)
+		avail--
+	}
+	if detail != "" {
+		avail--
 	}
 
-	return valueStr + rateStr
+	sx := (width - unitWidth) / textWidth
+	sy := avail / glyphHeight
+	if sx < 1 || sy < 1 {
+		return "", false
+	}
+
+	// Terminal cells are roughly twice as tall as wide, so keep pixels
+	// between square (2 columns per row) and tall (1 column per row)
+	if sx > 2*sy {
+		sx = 2 * sy
+	}
+	if sy > sx {
+		sy = sx
+	}
+
+	digits := renderBigText(number, sx, sy)
+	if unit != "" {
+		last := len(digits) - 1
+		digits[last] += strings.Repeat(" ", textWidth*sx-len([]rune(digits[last]))+1) + unit
+	}
+	pad := strings.Repeat(" ", (width-textWidth*sx-unitWidth)/2)
+
+	lines := make([]string, 0, height)
+	if c.Label != "" {
+		lines = append(lines, c.Label)
+	}
+	for i := 0; i < (avail-len(digits))/2; i++ {
+		lines = append(lines, "")
+	}
+	for _, d := range digits {
+		lines = append(lines, pad+d)
+	}
+	if detail != "" {
+		lines = append(lines, pad+detail)
+	}
+
+	return strings.Join(lines, "\n"), true
+}
+
+// splitUnit splits a formatted value into the number the block font can
+// draw and the unit suffix after it. Values with digits after the unit, as
+// in "1h30m0s", have no number to split off.
+func splitUnit(s string) (number, unit string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		_, ok := blockFont[r]
+		return !ok
+	})
+	if i < 0 {
+		return s, ""
+	}
+	if strings.ContainsAny(s[i:], "0123456789") {
+		return "", s
+	}
+	return s[:i], s[i:]
+}
+
+// signed formats a change in unit with an explicit sign.
+func signed(v float64, unit string) string {
+	if v >= 0 {
+		return "+" + FormatValue(v, unit)
+	}
+	return FormatValue(v, unit)
 }
@@ -0,0 +1,69 @@
+package chart
+
+import "strings"
+
+// glyphHeight is the number of pixel rows in every block-font glyph.
+const glyphHeight = 5
+
+// blockFont maps characters to 5-row glyphs, where '#' is a lit pixel.
+var blockFont = map[rune][glyphHeight]string{
+	'0': {"###", "# #", "# #", "# #", "###"},
+	'1': {"## ", " # ", " # ", " # ", "###"},
+	'2': {"###", "  #", "###", "#  ", "###"},
+	'3': {"###", "  #", "###", "  #", "###"},
+	'4': {"# #", "# #", "###", "  #", "  #"},
+	'5': {"###", "#  ", "###", "  #", "###"},
+	'6': {"###", "#  ", "###", "# #", "###"},
+	'7': {"###", "  #", "  #", "  #", "  #"},
+	'8': {"###", "# #", "###", "# #", "###"},
+	'9': {"###", "# #", "###", "  #", "###"},
+	'.': {" ", " ", " ", " ", "#"},
+	'-': {"   ", "   ", "###", "   ", "   "},
+	'+': {"   ", " # ", "###", " # ", "   "},
+}
+
+// bigTextWidth returns the pixel width of s in the block font, including
+// one pixel of spacing between glyphs. ok is false if s has an unsupported
+// character.
+func bigTextWidth(s string) (width int, ok bool) {
+	for i, r := range s {
+		g, exists := blockFont[r]
+		if !exists {
+			return 0, false
+		}
+		if i > 0 {
+			width++
+		}
+		width += len(g[0])
+	}
+	return width, true
+}
+
+// renderBigText draws s in the block font with every pixel scaled to
+// sx columns by sy rows. s must only contain characters in blockFont.
+func renderBigText(s string, sx, sy int) []string {
+	lines := make([]string, 0, glyphHeight*sy)
+
+	for row := 0; row < glyphHeight; row++ {
+		var sb strings.Builder
+		for i, r := range s {
+			if i > 0 {
+				sb.WriteString(strings.Repeat(" ", sx))
+			}
+			for _, px := range blockFont[r][row] {
+				cell := " "
+				if px == '#' {
+					cell = "█"
+				}
+				sb.WriteString(strings.Repeat(cell, sx))
+			}
+		}
+
+		line := strings.TrimRight(sb.String(), " ")
+		for j := 0; j < sy; j++ {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
@@ -2,7 +2,9 @@ package chart
 
 import (
 	"fmt"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/danqzq/rift/internal/stream"
 )
@@ -10,11 +12,21 @@ import (
 // Sparkline renders a dense time-series using unicode block characters.
 type Sparkline struct {
 	Config
+
+	// TimeMode maps a time span onto the width instead of showing the last
+	// width points. Points are bucketed per column and gaps stay empty.
+	TimeMode    bool
+	Span        time.Duration // time span shown in time mode (0 uses the span of the points)
+	Aggregation Aggregation   // avg, min, max, last, sum, count or rate per column in time mode
+	Band        bool          // if true and height > 1, draw each column's min/max range
 }
 
 // NewSparkline creates a new sparkline chart.
 func NewSparkline(config Config) *Sparkline {
-	return &Sparkline{Config: config}
+	return &Sparkline{
+		Config:      config,
+		Aggregation: AggAvg,
+	}
 }
 
 // Type returns "sparkline".
@@ -27,23 +39,27 @@ var sparkChars = []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
 
 // Render generates a sparkline visualization.
 func (s *Sparkline) Render(w *stream.Window, width, height int) string {
-	points := w.Points()
-	if len(points) == 0 {
+	n := w.Len()
+	if n == 0 {
 		return ""
 	}
 
-	if len(points) > width {
-		points = points[len(points)-width:]
+	if s.TimeMode {
+		return s.renderTime(w, width, height)
+	}
+
+	if n > width {
+		n = width
 	}
 	min, max := s.getScale(w)
 	if min == max {
 		// All values are the same, render middle block
-		return strings.Repeat(string(sparkChars[len(sparkChars)/2]), len(points))
+		return strings.Repeat(string(sparkChars[len(sparkChars)/2]), n)
 	}
 
-	// Build sparkline
+	// Build sparkline, marking anomalies in red
 	var sb strings.Builder
-	for _, p := range points {
+	for p := range w.Tail(width) {
 		// Normalize value to 0-1 range
 		normalized := (p.Value - min) / (max - min)
 		// Map to character index (0-7)
@@ -54,7 +70,7 @@ func (s *Sparkline) Render(w *stream.Window, width, height int) string {
 		if idx >= len(sparkChars) {
 			idx = len(sparkChars) - 1
 		}
-		sb.WriteRune(sparkChars[idx])
+		sb.WriteString(markAnomaly(string(sparkChars[idx]), p.Anomaly))
 	}
 
 	// Add label if configured
@@ -81,3 +97,168 @@ func (s *Sparkline) getScale(w *stream.Window) (min, max float64) {
 
 	return min, max
 }
+
+// renderTime draws the sparkline with the time span mapped onto the width.
+func (s *Sparkline) renderTime(w *stream.Window, width, height int) string {
+	prefix := ""
+	if s.Label != "" {
+		prefix = s.Label + ": "
+	}
+	cols := width - len(prefix)
+	if cols < 1 {
+		return ""
+	}
+
+	// The span ends at the newest point
+	first, _ := w.First()
+	last, _ := w.Last()
+	end := last.Timestamp
+	start := first.Timestamp
+	if s.Span > 0 {
+		start = end.Add(-s.Span)
+	}
+	if !end.After(start) {
+		start = end.Add(-time.Duration(cols))
+	}
+
+	buckets := w.Range(start, end, cols)
+	colSeconds := end.Sub(start).Seconds() / float64(cols)
+	// History from rollups can fall outside the raw window's scale, and
+	// sums, counts and rates are not on it at all, so those scale from zero
+	// to the values drawn
+	band := s.onValueScale()
+	min, max := s.getScale(w)
+	if !band {
+		min, max = 0, 0
+	}
+	for _, b := range buckets {
+		if b.Count == 0 {
+			continue
+		}
+		lo, hi := b.Min, b.Max
+		if !band {
+			lo = s.bucketValue(b, colSeconds)
+			hi = lo
+		}
+		if s.Min == nil {
+			min = math.Min(min, lo)
+		}
+		if s.Max == nil {
+			max = math.Max(max, hi)
+		}
+	}
+	if s.Min != nil {
+		min = *s.Min
+	}
+	if s.Max != nil {
+		max = *s.Max
+	}
+
+	if !s.Band || height < 2 {
+		var sb strings.Builder
+		sb.WriteString(prefix)
+		for _, b := range buckets {
+			if b.Count == 0 {
+				sb.WriteRune(' ')
+				continue
+			}
+			v := s.bucketValue(b, colSeconds)
+			sb.WriteString(markAnomaly(string(sparkChars[sparkIndex(v, min, max, len(sparkChars))]), b.Anomalies > 0))
+		}
+		return sb.String()
+	}
+
+	// Band: one row per level, band cells shaded and the value cell solid
+	lines := make([]string, height)
+	for row := 0; row < height; row++ {
+		var sb strings.Builder
+		if row == 0 {
+			sb.WriteString(prefix)
+		} else {
+			sb.WriteString(strings.Repeat(" ", len(prefix)))
+		}
+
+		level := height - 1 - row
+		for _, b := range buckets {
+			switch {
+			case b.Count == 0:
+				sb.WriteRune(' ')
+			case sparkIndex(s.bucketValue(b, colSeconds), min, max, height) == level:
+				sb.WriteString(markAnomaly("█", b.Anomalies > 0))
+			case band && sparkIndex(b.Min, min, max, height) <= level && level <= sparkIndex(b.Max, min, max, height):
+				sb.WriteRune('░')
+			default:
+				sb.WriteRune(' ')
+			}
+		}
+		lines[row] = sb.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CanBucket reports whether agg can be computed for the time-mode buckets
+// of a Sparkline, which only keep count, sum, min, max and last.
+func CanBucket(agg Aggregation) bool {
+	switch agg {
+	case AggAvg, AggMin, AggMax, AggLast, AggSum, AggCount, AggRate:
+		return true
+	}
+	return false
+}
+
+// bucketValue reduces a bucket with the configured aggregation (see
+// CanBucket).
+func (s *Sparkline) bucketValue(b stream.Bucket, colSeconds float64) float64 {
+	switch s.Aggregation {
+	case AggMin:
+		return b.Min
+	case AggMax:
+		return b.Max
+	case AggLast:
+		return b.Last
+	case AggSum:
+		return b.Sum
+	case AggCount:
+		return float64(b.Count)
+	case AggRate:
+		if colSeconds <= 0 {
+			return 0
+		}
+		return b.Sum / colSeconds
+	default:
+		return b.Mean()
+	}
+}
+
+// onValueScale reports whether bucket values are on the scale of the raw
+// values, so that a bucket's min/max band can be drawn alongside them.
+func (s *Sparkline) onValueScale() bool {
+	switch s.Aggregation {
+	case AggSum, AggCount, AggRate:
+		return false
+	}
+	return true
+}
+
+// markAnomaly colors a cell red if it holds an anomaly.
+func markAnomaly(cell string, anomaly bool) string {
+	if !anomaly {
+		return cell
+	}
+	return colorize(cell, "red")
+}
+
+// sparkIndex maps v within [min, max] to a level in [0, levels).
+func sparkIndex(v, min, max float64, levels int) int {
+	if max == min {
+		return levels / 2
+	}
+	idx := int((v - min) / (max - min) * float64(levels-1))
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= levels {
+		idx = levels - 1
+	}
+	return idx
+}
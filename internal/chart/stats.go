@@ -0,0 +1,103 @@
+package chart
+
+import (
+	"iter"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// labelSeries holds the points of a single label in arrival order.
+type labelSeries struct {
+	label  string
+	unit   string // unit of the latest point
+	values []float64
+	first  time.Time
+	last   time.Time
+}
+
+// groupByLabel splits points into one series per label, in order of first
+// appearance. Unlabeled points are grouped under "value".
+func groupByLabel(points iter.Seq[stream.DataPoint]) []*labelSeries {
+	index := make(map[string]*labelSeries)
+	var result []*labelSeries
+
+	for p := range points {
+		label := p.Label
+		if label == "" {
+			label = "value"
+		}
+
+		s, exists := index[label]
+		if !exists {
+			s = &labelSeries{label: label, first: p.Timestamp}
+			index[label] = s
+			result = append(result, s)
+		}
+		s.values = append(s.values, p.Value)
+		s.unit = p.Unit
+		s.last = p.Timestamp
+	}
+
+	return result
+}
+
+// sortedCopy returns the values sorted ascending without modifying the input.
+func sortedCopy(values []float64) []float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// percentile returns the q-th quantile (0-1) of sorted values using linear
+// interpolation between the closest ranks.
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return sorted[0]
+	}
+	if q >= 1 {
+		return sorted[len(sorted)-1]
+	}
+
+	pos := q * float64(len(sorted)-1)
+	lo := int(pos)
+	frac := pos - float64(lo)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
+
+// meanStdDev returns the mean and population standard deviation of values.
+func meanStdDev(values []float64) (mean, stddev float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// ratePerSecond returns how many points per second the series received over
+// its time span, or 0 if the span is empty.
+func (s *labelSeries) ratePerSecond() float64 {
+	span := s.last.Sub(s.first).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(len(s.values)-1) / span
+}
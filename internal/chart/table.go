@@ -0,0 +1,247 @@
+package chart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// TableColumns lists every column a Table can show, in default order.
+var TableColumns = []string{
+	"last", "min", "max", "mean", "stddev", "p50", "p95", "p99", "count", "rate", "spark",
+}
+
+// Table renders per-label statistics, one row per label in the window.
+type Table struct {
+	Config
+	Columns []string // columns to show, in order (see TableColumns)
+	SortBy  string   // column to sort rows by ("" or "label" sorts by label)
+	Desc    bool     // if true, sort in descending order
+}
+
+// NewTable creates a new statistics table showing all columns.
+func NewTable(config Config) *Table {
+	return &Table{
+		Config:  config,
+		Columns: TableColumns,
+	}
+}
+
+// Type returns "table".
+func (t *Table) Type() string {
+	return "table"
+}
+
+// ParseColumns parses a comma-separated list of table columns.
+func ParseColumns(spec string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !isTableColumn(name) {
+			return nil, fmt.Errorf("unknown table column %q", name)
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no table columns in %q", spec)
+	}
+	return columns, nil
+}
+
+// ParseSort parses a sort key such as "p95" (ascending) or "-p95" (descending).
+func ParseSort(spec string) (column string, desc bool, err error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if strings.HasPrefix(spec, "-") {
+		desc = true
+		spec = spec[1:]
+	}
+	if spec != "label" && (spec == "spark" || !isTableColumn(spec)) {
+		return "", false, fmt.Errorf("cannot sort by %q", spec)
+	}
+	return spec, desc, nil
+}
+
+func isTableColumn(name string) bool {
+	for _, c := range TableColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tableRow holds the computed statistics for one label.
+type tableRow struct {
+	series *labelSeries
+	stats  map[string]float64
+}
+
+// Render generates the statistics table.
+func (t *Table) Render(w *stream.Window, width, height int) string {
+	if w.Len() == 0 {
+		return ""
+	}
+
+	rows := make([]tableRow, 0)
+	for _, s := range groupByLabel(w.All()) {
+		rows = append(rows, tableRow{series: s, stats: computeRowStats(s)})
+	}
+	t.sortRows(rows)
+
+	// Header takes one line
+	if height > 1 && len(rows) > height-1 {
+		rows = rows[:height-1]
+	}
+
+	// Label column is as wide as the longest label
+	labelWidth := len("label")
+	for _, r := range rows {
+		if len(r.series.label) > labelWidth {
+			labelWidth = len(r.series.label)
+		}
+	}
+
+	// Format cells and size numeric columns to their widest value
+	cells := make([][]string, len(rows))
+	widths := make(map[string]int)
+	for i, r := range rows {
+		cells[i] = make([]string, len(t.Columns))
+		for j, col := range t.Columns {
+			if col == "spark" {
+				continue
+			}
+			cells[i][j] = formatStat(col, r.stats[col], r.series.unit)
+			widths[col] = max(widths[col], len(cells[i][j]), len(col))
+		}
+	}
+
+	// Drop columns from the right until the table fits
+	columns := t.Columns
+	used := labelWidth
+	for n, col := range columns {
+		need := widths[col] + 1
+		if col == "spark" {
+			need = 6
+		}
+		if used+need > width {
+			columns = columns[:n]
+			break
+		}
+		used += need
+	}
+	sparkWidth := min(width-used+6, 20)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-*s", labelWidth, "label"))
+	for _, col := range columns {
+		if col == "spark" {
+			sb.WriteString(" " + col)
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(" %*s", widths[col], col))
+	}
+
+	for i, r := range rows {
+		sb.WriteString("\n")
+		sb.WriteString(fmt.Sprintf("%-*s", labelWidth, r.series.label))
+		for j, col := range columns {
+			if col == "spark" {
+				sb.WriteString(" " + miniSpark(r.series.values, sparkWidth-1))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf(" %*s", widths[col], cells[i][j]))
+		}
+	}
+
+	return sb.String()
+}
+
+// sortRows orders rows by the configured column, falling back to the label.
+func (t *Table) sortRows(rows []tableRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if t.SortBy == "" || t.SortBy == "label" {
+			if t.Desc {
+				return a.series.label > b.series.label
+			}
+			return a.series.label < b.series.label
+		}
+		if a.stats[t.SortBy] == b.stats[t.SortBy] {
+			return a.series.label < b.series.label
+		}
+		if t.Desc {
+			return a.stats[t.SortBy] > b.stats[t.SortBy]
+		}
+		return a.stats[t.SortBy] < b.stats[t.SortBy]
+	})
+}
+
+// computeRowStats calculates every numeric table column for a series.
+func computeRowStats(s *labelSeries) map[string]float64 {
+	sorted := sortedCopy(s.values)
+	mean, stddev := meanStdDev(s.values)
+
+	return map[string]float64{
+		"last":   s.values[len(s.values)-1],
+		"min":    sorted[0],
+		"max":    sorted[len(sorted)-1],
+		"mean":   mean,
+		"stddev": stddev,
+		"p50":    percentile(sorted, 0.50),
+		"p95":    percentile(sorted, 0.95),
+		"p99":    percentile(sorted, 0.99),
+		"count":  float64(len(s.values)),
+		"rate":   s.ratePerSecond(),
+	}
+}
+
+// formatStat formats a statistic of values in unit for display in its
+// column. The count and rate (points per second) columns have no unit.
+func formatStat(column string, v float64, unit string) string {
+	if column == "count" {
+		return strconv.Itoa(int(v))
+	}
+	if unit != "" && column != "rate" {
+		return FormatValue(v, unit)
+	}
+	if v >= 1e7 || v <= -1e7 {
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// miniSpark renders the last width values as a sparkline scaled to their own
+// range. NaN and infinite values are left blank.
+func miniSpark(values []float64, width int) string {
+	if width <= 0 || len(values) == 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			lo = min(lo, v)
+			hi = max(hi, v)
+		}
+	}
+
+	var sb strings.Builder
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteRune(sparkChars[sparkIndex(v, lo, hi, len(sparkChars))])
+	}
+	return sb.String()
+}
@@ -0,0 +1,56 @@
+package chart
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatValue formats v, given in the canonical unit of a DataPoint (see
+// stream.DataPoint.Unit), with a prefix suited to its size: 0.0452 s is
+// "45.2ms", 90 s "1m30s" and 3.2e9 B "2.98GiB". Rates such as "B/s" keep
+// their suffix. Values without a unit get two decimals.
+func FormatValue(v float64, unit string) string {
+	if base, ok := strings.CutSuffix(unit, "/s"); ok && base != "" {
+		return FormatValue(v, base) + "/s"
+	}
+	abs := math.Abs(v)
+	switch unit {
+	case "s":
+		switch {
+		case abs == 0:
+			return "0s"
+		case abs < 1e-6:
+			return trimFloat(v*1e9) + "ns"
+		case abs < 1e-3:
+			return trimFloat(v*1e6) + "µs"
+		case abs < 1:
+			return trimFloat(v*1e3) + "ms"
+		case abs < 60:
+			return trimFloat(v) + "s"
+		}
+		return time.Duration(v * float64(time.Second)).Round(time.Second).String()
+	case "B":
+		prefixes := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+		i := 0
+		for abs >= 1024 && i < len(prefixes)-1 {
+			v /= 1024
+			abs /= 1024
+			i++
+		}
+		return trimFloat(v) + prefixes[i]
+	case "%":
+		return trimFloat(v) + "%"
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// trimFloat formats v with up to two decimals, dropping trailing zeros.
+func trimFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
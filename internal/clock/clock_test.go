@@ -0,0 +1,69 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_Now(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	f.Advance(90 * time.Second)
+	if got := f.Now(); !got.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("Now() = %v", got)
+	}
+	f.Set(start)
+	if got := f.Now(); !got.Equal(start) {
+		t.Errorf("Now() after Set = %v", got)
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	tk := f.NewTicker(time.Second)
+
+	f.Advance(500 * time.Millisecond)
+	select {
+	case <-tk.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	f.Advance(500 * time.Millisecond)
+	select {
+	case got := <-tk.C():
+		if !got.Equal(start.Add(time.Second)) {
+			t.Errorf("tick at %v, want %v", got, start.Add(time.Second))
+		}
+	default:
+		t.Fatal("ticker did not fire")
+	}
+
+	// Missed ticks are dropped, leaving one pending
+	f.Advance(5 * time.Second)
+	<-tk.C()
+	select {
+	case <-tk.C():
+		t.Fatal("expected missed ticks to be dropped")
+	default:
+	}
+
+	tk.Stop()
+	f.Advance(time.Minute)
+	select {
+	case <-tk.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestOr(t *testing.T) {
+	if Or(nil) != Real {
+		t.Error("Or(nil) should be Real")
+	}
+	f := NewFake(time.Time{})
+	if Or(f) != f {
+		t.Error("Or(f) should be f")
+	}
+}
@@ -0,0 +1,187 @@
+// Package derive computes virtual series from expressions over other series,
+// such as "error_rate = errors / requests * 100".
+package derive
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/danqzq/rift/internal/expr"
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// Fill controls how a series without a value in a bucket is treated.
+type Fill int
+
+const (
+	// FillSkip emits nothing for a bucket missing any referenced series.
+	FillSkip Fill = iota
+	// FillZero treats missing series as 0.
+	FillZero
+	// FillPrevious carries the series' last known value forward.
+	FillPrevious
+)
+
+// ParseFill parses "skip", "zero" or "prev".
+func ParseFill(s string) (Fill, error) {
+	switch s {
+	case "skip", "":
+		return FillSkip, nil
+	case "zero":
+		return FillZero, nil
+	case "prev", "previous":
+		return FillPrevious, nil
+	}
+	return 0, fmt.Errorf("invalid fill %q, expected skip, zero or prev", s)
+}
+
+// Deriver aligns incoming points into time buckets and evaluates derived
+// expressions once a bucket is complete. Each bucket keeps the last value of
+// every label that reported in it. A bucket is complete when a point arrives
+// two buckets later, which leaves room for inputs that interleave unevenly.
+//
+// Expressions that fail to evaluate (a missing series under FillSkip, or a
+// zero denominator) produce no point for that bucket.
+type Deriver struct {
+	defs   []expr.Definition
+	bucket time.Duration
+	fill   Fill
+
+	open   map[time.Time]expr.Map // bucket start -> last value per label
+	closed time.Time              // buckets before this have been emitted
+	last   expr.Map               // last known value per label, for FillPrevious
+}
+
+// New creates a deriver for defs using buckets of the given width.
+func New(defs []expr.Definition, bucket time.Duration, fill Fill) *Deriver {
+	if bucket <= 0 {
+		bucket = time.Second
+	}
+	return &Deriver{
+		defs:   defs,
+		bucket: bucket,
+		fill:   fill,
+		open:   make(map[time.Time]expr.Map),
+		last:   make(expr.Map),
+	}
+}
+
+// Add records a point and returns derived points for any buckets it
+// completed. Points for buckets that were already emitted are ignored.
+func (d *Deriver) Add(p stream.DataPoint) []stream.DataPoint {
+	if p.Label == "" {
+		return nil
+	}
+	start := p.Timestamp.Truncate(d.bucket)
+	if start.Before(d.closed) {
+		return nil
+	}
+
+	values, ok := d.open[start]
+	if !ok {
+		values = make(expr.Map)
+		d.open[start] = values
+	}
+	values[p.Label] = p.Value
+
+	return d.emit(start.Add(-d.bucket))
+}
+
+// Flush evaluates all open buckets. Call it when the input ends.
+func (d *Deriver) Flush() []stream.DataPoint {
+	var latest time.Time
+	for start := range d.open {
+		if start.After(latest) {
+			latest = start
+		}
+	}
+	return d.emit(latest.Add(d.bucket))
+}
+
+// emit evaluates and removes buckets that start before until, oldest first.
+func (d *Deriver) emit(until time.Time) []stream.DataPoint {
+	var starts []time.Time
+	for start := range d.open {
+		if start.Before(until) {
+			starts = append(starts, start)
+		}
+	}
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+
+	var out []stream.DataPoint
+	for _, start := range starts {
+		values := d.open[start]
+		delete(d.open, start)
+		out = append(out, d.evaluate(start, values)...)
+
+		for label, v := range values {
+			d.last[label] = v
+		}
+	}
+	if until.After(d.closed) {
+		d.closed = until
+	}
+	return out
+}
+
+// evaluate computes every definition for one bucket. Derived values are
+// visible to later definitions, so they can build on each other.
+func (d *Deriver) evaluate(start time.Time, values expr.Map) []stream.DataPoint {
+	env := &fillEnv{bucket: values, prev: d.last, fill: d.fill}
+
+	var out []stream.DataPoint
+	for _, def := range d.defs {
+		v, ok := def.Expr.Eval(env)
+		if !ok {
+			continue
+		}
+		values[def.Name] = v
+		out = append(out, stream.DataPoint{
+			Timestamp: start,
+			Value:     v,
+			Label:     def.Name,
+		})
+	}
+	return out
+}
+
+// fillEnv resolves series from one bucket, filling gaps per the fill mode.
+type fillEnv struct {
+	bucket expr.Map
+	prev   expr.Map
+	fill   Fill
+}
+
+// Value implements expr.Env.
+func (e *fillEnv) Value(name string) (float64, bool) {
+	if v, ok := e.bucket[name]; ok {
+		return v, true
+	}
+	switch e.fill {
+	case FillZero:
+		return 0, true
+	case FillPrevious:
+		return e.prev.Value(name)
+	}
+	return 0, false
+}
+
+// Values implements expr.Env. Under FillPrevious, series that reported in
+// earlier buckets but not this one are included with their last value.
+func (e *fillEnv) Values(pattern string) []float64 {
+	values := e.bucket.Values(pattern)
+	if e.fill != FillPrevious {
+		return values
+	}
+	for name, v := range e.prev {
+		if _, ok := e.bucket[name]; ok {
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
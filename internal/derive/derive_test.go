@@ -0,0 +1,128 @@
+package derive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danqzq/rift/internal/expr"
+	"github.com/danqzq/rift/internal/stream"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func point(label string, sec float64, v float64) stream.DataPoint {
+	return stream.DataPoint{
+		Label:     label,
+		Value:     v,
+		Timestamp: start.Add(time.Duration(sec * float64(time.Second))),
+	}
+}
+
+func mustDefs(t *testing.T, specs ...string) []expr.Definition {
+	t.Helper()
+	var defs []expr.Definition
+	for _, s := range specs {
+		def, err := expr.ParseDefinition(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// feed adds points in order and flushes, returning every derived value.
+func feed(d *Deriver, points ...stream.DataPoint) []stream.DataPoint {
+	var out []stream.DataPoint
+	for _, p := range points {
+		out = append(out, d.Add(p)...)
+	}
+	return append(out, d.Flush()...)
+}
+
+func TestDeriver_AlignsUnevenInputs(t *testing.T) {
+	d := New(mustDefs(t, "error_rate = errors / requests * 100"), time.Second, FillSkip)
+
+	out := feed(d,
+		point("requests", 0.1, 100),
+		point("requests", 1.2, 200),
+		point("errors", 0.9, 5), // arrives after the next bucket started
+		point("errors", 1.5, 10),
+		point("requests", 2.0, 50),
+		point("requests", 3.5, 0),
+		point("errors", 3.6, 1), // zero denominator
+	)
+
+	want := []float64{5, 5}
+	if len(out) != len(want) {
+		t.Fatalf("got %d points %v, want %v", len(out), out, want)
+	}
+	for i, p := range out {
+		if p.Label != "error_rate" || p.Value != want[i] {
+			t.Errorf("point %d = %s %v, want error_rate %v", i, p.Label, p.Value, want[i])
+		}
+		if wantAt := start.Add(time.Duration(i) * time.Second); !p.Timestamp.Equal(wantAt) {
+			t.Errorf("point %d at %v, want %v", i, p.Timestamp, wantAt)
+		}
+	}
+}
+
+func TestDeriver_Fill(t *testing.T) {
+	points := []stream.DataPoint{
+		point("a", 0, 1), point("b", 0, 10),
+		point("a", 1, 2), // b missing
+	}
+
+	tests := []struct {
+		fill Fill
+		want []float64
+	}{
+		{FillSkip, []float64{11}},
+		{FillZero, []float64{11, 2}},
+		{FillPrevious, []float64{11, 12}},
+	}
+	for _, tt := range tests {
+		d := New(mustDefs(t, "total = a + b"), time.Second, tt.fill)
+		out := feed(d, points...)
+		var got []float64
+		for _, p := range out {
+			got = append(got, p.Value)
+		}
+		if len(got) != len(tt.want) || got[0] != tt.want[0] || got[len(got)-1] != tt.want[len(tt.want)-1] {
+			t.Errorf("fill %d: got %v, want %v", tt.fill, got, tt.want)
+		}
+	}
+}
+
+func TestDeriver_GlobAndChaining(t *testing.T) {
+	d := New(mustDefs(t, "total = sum(cpu_*)", "idle = 100 - total"), time.Second, FillSkip)
+	out := feed(d, point("cpu_user", 0, 30), point("cpu_sys", 0, 15), point("mem", 0, 99))
+
+	got := make(map[string]float64)
+	for _, p := range out {
+		got[p.Label] = p.Value
+	}
+	if got["total"] != 45 || got["idle"] != 55 {
+		t.Errorf("got %v, want total=45 idle=55", got)
+	}
+}
+
+func TestDeriver_DropsLatePoints(t *testing.T) {
+	d := New(mustDefs(t, "x2 = x * 2"), time.Second, FillSkip)
+	out := feed(d, point("x", 0, 1), point("x", 5, 2), point("x", 0.5, 100))
+
+	if len(out) != 2 || out[0].Value != 2 || out[1].Value != 4 {
+		t.Errorf("got %v, want values [2 4]", out)
+	}
+}
+
+func TestParseFill(t *testing.T) {
+	for s, want := range map[string]Fill{"": FillSkip, "skip": FillSkip, "zero": FillZero, "prev": FillPrevious} {
+		if got, err := ParseFill(s); err != nil || got != want {
+			t.Errorf("ParseFill(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseFill("linear"); err == nil {
+		t.Error("ParseFill(linear) should fail")
+	}
+}
@@ -0,0 +1,317 @@
+// Package expr parses and evaluates arithmetic expressions over named series,
+// such as "errors / requests * 100" or "sum(cpu_*)".
+package expr
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Env resolves series names while evaluating an expression.
+type Env interface {
+	// Value returns the value of the series with the given name.
+	Value(name string) (float64, bool)
+	// Values returns the values of all series whose names match a glob
+	// pattern (see path.Match).
+	Values(pattern string) []float64
+}
+
+// SeriesEnv is an Env that also keeps each series' recent history, so
+// functions such as p95(latency) or rate(errors) can be computed over it.
+type SeriesEnv interface {
+	Env
+	// Series applies a window function to the named series.
+	Series(fn, name string) (float64, bool)
+}
+
+// IsSeriesFunc reports whether fn is a window function: last, avg, mean,
+// min, max, sum, count, stddev, rate or a percentile such as p95 or p99.9.
+func IsSeriesFunc(fn string) bool {
+	switch fn {
+	case "last", "avg", "mean", "min", "max", "sum", "count", "stddev", "rate":
+		return true
+	}
+	_, ok := Percentile(fn)
+	return ok
+}
+
+// Percentile parses a percentile function name such as "p95" or "p99.9"
+// into a quantile between 0 and 1.
+func Percentile(fn string) (float64, bool) {
+	if len(fn) < 2 || fn[0] != 'p' {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(fn[1:], 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, false
+	}
+	return v / 100, true
+}
+
+// Node is a parsed expression.
+type Node interface {
+	// Eval computes the expression. It returns false if a series is missing
+	// or the result is not a finite number (e.g. division by zero).
+	Eval(env Env) (float64, bool)
+	String() string
+}
+
+// Num is a numeric literal.
+type Num float64
+
+// Ref refers to a series by name, or to several by glob inside aggregate
+// functions.
+type Ref string
+
+// Unary is a negation.
+type Unary struct {
+	X Node
+}
+
+// Binary is an arithmetic operation: + - * /.
+type Binary struct {
+	Op   byte
+	L, R Node
+}
+
+// Call is a function call such as sum(cpu_*).
+type Call struct {
+	Func string
+	Args []Node
+}
+
+// Eval implements Node.
+func (n Num) Eval(Env) (float64, bool) { return float64(n), true }
+
+func (n Num) String() string { return strconv.FormatFloat(float64(n), 'g', -1, 64) }
+
+// Eval implements Node.
+func (r Ref) Eval(env Env) (float64, bool) { return env.Value(string(r)) }
+
+func (r Ref) String() string { return string(r) }
+
+// Eval implements Node.
+func (u *Unary) Eval(env Env) (float64, bool) {
+	v, ok := u.X.Eval(env)
+	return -v, ok
+}
+
+func (u *Unary) String() string { return "-" + u.X.String() }
+
+// Eval implements Node.
+func (b *Binary) Eval(env Env) (float64, bool) {
+	l, ok := b.L.Eval(env)
+	if !ok {
+		return 0, false
+	}
+	r, ok := b.R.Eval(env)
+	if !ok {
+		return 0, false
+	}
+
+	var v float64
+	switch b.Op {
+	case '+':
+		v = l + r
+	case '-':
+		v = l - r
+	case '*':
+		v = l * r
+	case '/':
+		if r == 0 {
+			return 0, false
+		}
+		v = l / r
+	}
+	return v, finite(v)
+}
+
+func (b *Binary) String() string {
+	return fmt.Sprintf("(%s %c %s)", b.L, b.Op, b.R)
+}
+
+// functions are the aggregate functions available to Call. Each receives
+// the values of all its arguments, with glob references expanded.
+var functions = map[string]func([]float64) (float64, bool){
+	"sum": func(vs []float64) (float64, bool) {
+		var s float64
+		for _, v := range vs {
+			s += v
+		}
+		return s, len(vs) > 0
+	},
+	"avg": func(vs []float64) (float64, bool) {
+		var s float64
+		for _, v := range vs {
+			s += v
+		}
+		return s / float64(len(vs)), len(vs) > 0
+	},
+	"min": func(vs []float64) (float64, bool) {
+		if len(vs) == 0 {
+			return 0, false
+		}
+		m := vs[0]
+		for _, v := range vs[1:] {
+			m = math.Min(m, v)
+		}
+		return m, true
+	},
+	"max": func(vs []float64) (float64, bool) {
+		if len(vs) == 0 {
+			return 0, false
+		}
+		m := vs[0]
+		for _, v := range vs[1:] {
+			m = math.Max(m, v)
+		}
+		return m, true
+	},
+	"count": func(vs []float64) (float64, bool) {
+		return float64(len(vs)), true
+	},
+	"abs": func(vs []float64) (float64, bool) {
+		if len(vs) != 1 {
+			return 0, false
+		}
+		return math.Abs(vs[0]), true
+	},
+}
+
+// Eval implements Node. With a SeriesEnv, window functions applied to a
+// single name are computed over that series' history; otherwise they
+// aggregate the current values of every series the arguments match.
+func (c *Call) Eval(env Env) (float64, bool) {
+	if senv, ok := env.(SeriesEnv); ok && len(c.Args) == 1 && IsSeriesFunc(c.Func) {
+		if ref, ok := c.Args[0].(Ref); ok {
+			v, ok := senv.Series(c.Func, string(ref))
+			return v, ok && finite(v)
+		}
+	}
+
+	fn, ok := functions[c.Func]
+	if !ok {
+		return 0, false
+	}
+
+	var values []float64
+	for _, arg := range c.Args {
+		if ref, ok := arg.(Ref); ok {
+			values = append(values, env.Values(string(ref))...)
+			continue
+		}
+		v, ok := arg.Eval(env)
+		if !ok {
+			return 0, false
+		}
+		values = append(values, v)
+	}
+
+	v, ok := fn(values)
+	return v, ok && finite(v)
+}
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return fmt.Sprintf("%s(%s)", c.Func, strings.Join(args, ", "))
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// Refs returns the series names and patterns an expression refers to.
+func Refs(n Node) []string {
+	var refs []string
+	var walk func(Node)
+	walk = func(n Node) {
+		switch n := n.(type) {
+		case Ref:
+			refs = append(refs, string(n))
+		case *Unary:
+			walk(n.X)
+		case *Binary:
+			walk(n.L)
+			walk(n.R)
+		case *Call:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		}
+	}
+	walk(n)
+	return refs
+}
+
+// HistoryFunc returns the first function in n that only works over a
+// series' history, such as p95 or rate, so it cannot be evaluated without a
+// SeriesEnv. ok is false if there is none.
+func HistoryFunc(n Node) (fn string, ok bool) {
+	var walk func(Node) bool
+	walk = func(n Node) bool {
+		switch n := n.(type) {
+		case *Unary:
+			return walk(n.X)
+		case *Binary:
+			return walk(n.L) || walk(n.R)
+		case *Call:
+			if _, plain := functions[n.Func]; !plain {
+				fn = n.Func
+				return true
+			}
+			for _, a := range n.Args {
+				if walk(a) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return fn, walk(n)
+}
+
+// Map is an Env backed by a map of series values.
+type Map map[string]float64
+
+// Value implements Env.
+func (m Map) Value(name string) (float64, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// Values implements Env.
+func (m Map) Values(pattern string) []float64 {
+	var values []float64
+	for name, v := range m {
+		if ok, _ := path.Match(pattern, name); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Definition is a named expression: "name = expr".
+type Definition struct {
+	Name string
+	Expr Node
+}
+
+// ParseDefinition parses "name = expr".
+func ParseDefinition(s string) (Definition, error) {
+	name, body, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return Definition{}, fmt.Errorf("invalid definition %q, expected name = expression", s)
+	}
+	n, err := Parse(body)
+	if err != nil {
+		return Definition{}, err
+	}
+	return Definition{Name: name, Expr: n}, nil
+}
@@ -0,0 +1,114 @@
+package expr
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	env := Map{
+		"errors":   5,
+		"requests": 200,
+		"cpu_user": 30,
+		"cpu_sys":  10,
+		"http-5xx": 2,
+		"zero":     0,
+	}
+
+	tests := []struct {
+		expr   string
+		want   float64
+		wantOK bool
+	}{
+		{"errors / requests * 100", 2.5, true},
+		{"1 + 2 * 3", 7, true},
+		{"errors*2", 10, true},
+		{"(1 + 2) * 3", 9, true},
+		{"-errors + 10", 5, true},
+		{"10 - 4 - 3", 3, true},
+		{"1.5e2 / 3", 50, true},
+		{"sum(cpu_*)", 40, true},
+		{"avg(cpu_*)", 20, true},
+		{"max(cpu_*, 35)", 35, true},
+		{"min(cpu_user, cpu_sys)", 10, true},
+		{"count(cpu_*)", 2, true},
+		{"count(mem_*)", 0, true},
+		{"abs(-3)", 3, true},
+		{`"http-5xx" * 2`, 4, true},
+		{"errors / zero", 0, false},
+		{"missing + 1", 0, false},
+		{"sum(mem_*)", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, ok := n.Eval(env)
+			if ok != tt.wantOK {
+				t.Fatalf("Eval ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"a b",
+		"nope(a)",
+		"sum(a,)",
+		`"unterminated`,
+		"a $ b",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) should fail", s)
+		}
+	}
+}
+
+func TestParseDefinition(t *testing.T) {
+	def, err := ParseDefinition("error_rate = errors / requests * 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Name != "error_rate" {
+		t.Errorf("Name = %q, want error_rate", def.Name)
+	}
+	if got := Refs(def.Expr); len(got) != 2 || got[0] != "errors" || got[1] != "requests" {
+		t.Errorf("Refs = %v, want [errors requests]", got)
+	}
+
+	if _, err := ParseDefinition("errors / requests"); err == nil {
+		t.Error("definition without a name should fail")
+	}
+}
+
+func TestHistoryFunc(t *testing.T) {
+	tests := []struct {
+		src    string
+		want   string
+		wantOK bool
+	}{
+		{"errors / requests", "", false},
+		{"avg(cpu.*) + max(a, b)", "", false},
+		{"p95(a) + b", "p95", true},
+		{"abs(-rate(bytes))", "rate", true},
+	}
+	for _, tt := range tests {
+		n, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fn, ok := HistoryFunc(n); fn != tt.want || ok != tt.wantOK {
+			t.Errorf("HistoryFunc(%q) = %q, %v, want %q, %v", tt.src, fn, ok, tt.want, tt.wantOK)
+		}
+	}
+}
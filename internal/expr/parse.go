@@ -0,0 +1,230 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Parse parses an arithmetic expression. Names may contain letters, digits,
+// '_' and '.'; other names can be written in double quotes, e.g. "http-5xx".
+// Inside function arguments names may also contain the glob characters '*'
+// and '?', as in sum(cpu_*).
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | name | name "(" [expr { "," expr }] ")" | "(" expr ")"
+func Parse(s string) (Node, error) {
+	p := &parser{src: s}
+	p.next()
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return n, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokName
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+	err error
+
+	depth int // function call nesting; globs are allowed when > 0
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("expression %q at %d: %s", p.src, p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' ||
+		p.depth > 0 && (r == '*' || r == '?')
+}
+
+// next scans the next token into p.tok.
+func (p *parser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '"':
+		end := strings.IndexByte(p.src[p.pos+1:], '"')
+		if end < 0 {
+			p.err = fmt.Errorf("expression %q at %d: unterminated quoted name", p.src, start)
+			p.tok = token{kind: tokEOF, pos: start}
+			return
+		}
+		p.pos += end + 2
+		p.tok = token{kind: tokName, text: p.src[start+1 : p.pos-1], pos: start}
+	case c >= '0' && c <= '9' || c == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || strings.IndexByte(".eE", p.src[p.pos]) >= 0 ||
+			(p.src[p.pos] == '-' || p.src[p.pos] == '+') && strings.IndexByte("eE", p.src[p.pos-1]) >= 0) {
+			p.pos++
+		}
+		// A number immediately followed by name characters is a name, e.g. "5xx"
+		if p.pos < len(p.src) && p.isNameRune(rune(p.src[p.pos])) {
+			p.scanName()
+			p.tok = token{kind: tokName, text: p.src[start:p.pos], pos: start}
+			return
+		}
+		p.tok = token{kind: tokNum, text: p.src[start:p.pos], pos: start}
+	case p.isNameRune(rune(c)) || c >= 0x80:
+		p.scanName()
+		p.tok = token{kind: tokName, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+	}
+}
+
+func (p *parser) scanName() {
+	for p.pos < len(p.src) {
+		r := rune(p.src[p.pos])
+		if r < 0x80 && !p.isNameRune(r) {
+			break
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) expr() (Node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: op, L: left, R: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (Node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: op, L: left, R: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (Node, error) {
+	if p.isOp("-") {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{X: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	tok := p.tok
+	switch {
+	case tok.kind == tokNum:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok.text)
+		}
+		p.next()
+		return Num(v), nil
+
+	case tok.kind == tokName:
+		p.next()
+		if !p.isOp("(") {
+			return Ref(tok.text), nil
+		}
+		if _, ok := functions[tok.text]; !ok && !IsSeriesFunc(tok.text) {
+			return nil, fmt.Errorf("expression %q at %d: unknown function %q", p.src, tok.pos, tok.text)
+		}
+		p.depth++
+		p.next()
+		call := &Call{Func: tok.text}
+		for !p.isOp(")") {
+			if len(call.Args) > 0 {
+				if !p.isOp(",") {
+					return nil, p.errorf("expected , or )")
+				}
+				p.next()
+			}
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+		}
+		p.depth--
+		p.next()
+		return call, nil
+
+	case p.isOp("("):
+		p.next()
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf("expected )")
+		}
+		p.next()
+		return n, nil
+
+	case tok.kind == tokEOF:
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("unexpected %q", tok.text)
+}
@@ -0,0 +1,273 @@
+package format
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// HeaderMode says whether a CSV stream starts with a header row.
+type HeaderMode string
+
+const (
+	HeaderAuto HeaderMode = "auto" // a leading row with no numbers is a header
+	HeaderYes  HeaderMode = "yes"  // the first row is always a header
+	HeaderNo   HeaderMode = "no"   // there is no header
+)
+
+// ParseHeaderMode parses a header mode name.
+func ParseHeaderMode(s string) (HeaderMode, error) {
+	switch m := HeaderMode(s); m {
+	case HeaderAuto, HeaderYes, HeaderNo:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown header mode %q, expected auto, yes or no", s)
+}
+
+// CSVOptions configures how CSV and TSV lines are split.
+type CSVOptions struct {
+	// Delimiter separates fields. Zero detects tab, semicolon or comma from
+	// the first row; lines with only semicolons count as CSV if their fields
+	// have no spaces. With a semicolon, numbers may use a decimal comma.
+	Delimiter rune
+	Header    HeaderMode // "" = HeaderAuto
+}
+
+// csvState is what a Parser remembers between the rows of one CSV stream.
+type csvState struct {
+	comma  rune
+	header []string
+	data   bool // a data row has been seen
+}
+
+// parseCSV parses one RFC 4180 record. Quoted fields may contain the
+// delimiter and escaped ("") quotes, but not newlines, since every line is
+// a record of its own.
+//
+// Once a header row has been seen, rows are read as objects keyed by column
+// name and take the JSON rules and paths: a recognised or chosen time column
+// sets the timestamp, a label column (by default the first text column)
+// prefixes the column names, and every other numeric column becomes a point.
+// Without a header, a row of a name and a number is a labelled point and
+// other rows yield their numeric columns labelled by index.
+func parseCSV(line string, now time.Time, opts CSVOptions, state *csvState, paths JSONPaths) ParseResult {
+	result := ParseResult{Format: FormatCSV}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return result
+	}
+
+	if state.comma == 0 {
+		state.comma = opts.Delimiter
+		if state.comma == 0 {
+			state.comma = detectDelimiter(line)
+		}
+	}
+	fields, ok := splitCSV(line, state.comma)
+	if !ok {
+		return result
+	}
+	decimalComma := state.comma == ';'
+
+	if isHeader(fields, opts.Header, state, decimalComma) {
+		state.header = fields
+		return result
+	}
+	state.data = true
+
+	if state.header != nil {
+		result.Points = csvRecord(fields, line, now, state.header, decimalComma, paths)
+		return result
+	}
+
+	// A column holding a date string is the event timestamp, not data
+	ts := now
+	var hasTime bool
+	var kept []csvField
+	for i, f := range fields {
+		if !hasTime {
+			if t, ok := isDateString(f); ok {
+				ts, hasTime = t, true
+				continue
+			}
+		}
+		kept = append(kept, csvField{index: i, text: f})
+	}
+
+	newPoint := func(label string, q quantity) stream.DataPoint {
+		dp := stream.NewLabeledDataPointAt(label, q.value, ts)
+		dp.Raw = line
+		dp.Unit = q.unit
+		return dp
+	}
+
+	if len(kept) == 2 {
+		if q, ok := parseNumber(kept[1].text, decimalComma); ok {
+			result.Points = []stream.DataPoint{newPoint(kept[0].text, q)}
+			return result
+		}
+	}
+
+	for _, f := range kept {
+		if q, ok := parseNumber(f.text, decimalComma); ok {
+			result.Points = append(result.Points, newPoint(strconv.Itoa(f.index), q))
+		}
+	}
+
+	return result
+}
+
+// csvField is a CSV column value along with its original position.
+type csvField struct {
+	index int
+	text  string
+}
+
+// csvRecord turns a row into an object keyed by the header and extracts
+// points from it the way extractFromMap does for JSON.
+func csvRecord(fields []string, raw string, now time.Time, header []string, decimalComma bool, paths JSONPaths) []stream.DataPoint {
+	obj := make(map[string]any, len(fields))
+	var names []string
+	for i, f := range fields {
+		name := strconv.Itoa(i)
+		if i < len(header) && header[i] != "" {
+			name = header[i]
+		}
+		names = append(names, name)
+		if q, ok := parseNumber(f, decimalComma); ok && q.unit == "" {
+			obj[name] = q.value
+		} else if ok {
+			obj[name] = q
+		} else if f != "" {
+			obj[name] = f
+		}
+	}
+
+	_, timeCol, hasTime := findTimestamp(obj)
+	if paths.Time != nil {
+		timeCol = paths.Time.String()
+	} else if !hasTime {
+		for i, f := range fields {
+			if _, ok := isDateString(f); ok {
+				paths.Time = Path{{key: names[i]}}
+				timeCol = names[i]
+				break
+			}
+		}
+	}
+	// Rows are told apart by their label, so it always prefixes the columns
+	if paths.Label == nil {
+		paths.Label = labelColumn(obj, names, timeCol)
+	}
+
+	return extractFromMap(obj, raw, now, paths)
+}
+
+// labelColumn returns the column labelling a CSV row: a recognised label
+// field, or else the first text column other than the time column.
+func labelColumn(obj map[string]any, names []string, timeCol string) Path {
+	for _, lf := range labelFields {
+		if s, ok := obj[lf].(string); ok && s != "" {
+			return Path{{key: lf}}
+		}
+	}
+	for _, name := range names {
+		if s, ok := obj[name].(string); ok && name != timeCol && s != "" {
+			return Path{{key: name}}
+		}
+	}
+	return nil
+}
+
+// isHeader reports whether fields is a header row, which replaces any
+// earlier header. In auto mode that is a row without numbers or dates,
+// either before the first data row or repeating the header's width, as when
+// a polled command prints its header every run.
+func isHeader(fields []string, mode HeaderMode, state *csvState, decimalComma bool) bool {
+	switch mode {
+	case HeaderNo:
+		return false
+	case HeaderYes:
+		if !state.data && state.header == nil {
+			return true
+		}
+	}
+	if state.data && len(fields) != len(state.header) {
+		return false
+	}
+	text := false
+	for _, f := range fields {
+		if f == "" {
+			continue
+		}
+		if _, ok := parseNumber(f, decimalComma); ok {
+			return false
+		}
+		if _, ok := isDateString(f); ok {
+			return false
+		}
+		text = true
+	}
+	return text
+}
+
+// detectDelimiter guesses the delimiter of a CSV line.
+func detectDelimiter(line string) rune {
+	switch {
+	case strings.Contains(line, "\t") && !strings.Contains(line, ","):
+		return '\t'
+	case strings.Contains(line, ";"):
+		return ';'
+	}
+	return ','
+}
+
+// semicolonRow reports whether a line looks like a row of semicolon
+// separated values: two or more fields, none containing spaces, unlike text
+// that merely has a semicolon in it.
+func semicolonRow(line string) bool {
+	fields, ok := splitCSV(strings.TrimSpace(line), ';')
+	if !ok || len(fields) < 2 {
+		return false
+	}
+	for _, f := range fields {
+		if strings.ContainsFunc(f, unicode.IsSpace) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCSV splits a line into trimmed fields.
+func splitCSV(line string, comma rune) ([]string, bool) {
+	r := csv.NewReader(strings.NewReader(line))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = comma != '\t'
+	fields, err := r.Read()
+	if err != nil {
+		return nil, false
+	}
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields, true
+}
+
+// parseNumber parses a CSV number, which may have a unit (see
+// parseQuantity). With decimalComma, "1.234,5" and "1,5" use a comma as the
+// decimal separator, as spreadsheets do in many locales.
+func parseNumber(s string, decimalComma bool) (quantity, bool) {
+	if decimalComma && strings.Contains(s, ",") {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	f, unit, ok := parseQuantity(s)
+	return quantity{value: f, unit: unit}, ok
+}
@@ -3,9 +3,12 @@ package format
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/danqzq/rift/internal/stream"
 )
@@ -17,6 +20,9 @@ const (
 	FormatJSON FormatType = "json"
 	FormatCSV  FormatType = "csv"
 	FormatRaw  FormatType = "raw"
+
+	// FormatPattern is reported for lines parsed with a Pattern.
+	FormatPattern FormatType = "pattern"
 )
 
 // ParseResult holds the result of parsing a line of input.
@@ -25,6 +31,15 @@ type ParseResult struct {
 	Format FormatType
 }
 
+// ParseFormat parses a format name.
+func ParseFormat(s string) (FormatType, error) {
+	switch f := FormatType(s); f {
+	case FormatJSON, FormatCSV, FormatRaw:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected json, csv or raw", s)
+}
+
 // Detect analyzes a line of input and returns the detected format.
 func Detect(line string) FormatType {
 	line = strings.TrimSpace(line)
@@ -46,39 +61,44 @@ func Detect(line string) FormatType {
 
 // Parse attempts to parse a line using the specified format.
 func Parse(line string, format FormatType) ParseResult {
-	switch format {
-	case FormatJSON:
-		return parseJSON(line)
-	case FormatCSV:
-		return parseCSV(line)
-	default:
-		return parseRaw(line)
-	}
+	return ParseAt(line, format, time.Now())
+}
+
+// ParseAt parses a line using the specified format, stamping points without
+// a timestamp of their own with now.
+func ParseAt(line string, format FormatType, now time.Time) ParseResult {
+	p := Parser{Format: format}
+	return p.ParseAt(line, now)
 }
 
 // AutoParse detects the format and parses the line automatically.
 func AutoParse(line string) ParseResult {
-	format := Detect(line)
-	result := Parse(line, format)
-	result.Format = format
-	return result
+	return AutoParseAt(line, time.Now())
+}
+
+// AutoParseAt is like AutoParse but stamps points that carry no timestamp
+// in the input with the given arrival time instead of the current time, as
+// when replaying a recorded session.
+func AutoParseAt(line string, arrival time.Time) ParseResult {
+	var p Parser
+	return p.ParseAt(line, arrival)
 }
 
 // parseJSON handles JSON objects and arrays.
-func parseJSON(line string) ParseResult {
+func parseJSON(line string, now time.Time, paths JSONPaths) ParseResult {
 	line = strings.TrimSpace(line)
 	result := ParseResult{Format: FormatJSON}
 
 	var obj map[string]any
 	if err := json.Unmarshal([]byte(line), &obj); err == nil {
-		points := extractFromMap(obj, line)
+		points := extractFromMap(obj, line, now, paths)
 		result.Points = points
 		return result
 	}
 
 	var arr []any
 	if err := json.Unmarshal([]byte(line), &arr); err == nil {
-		points := extractFromArray(arr, line)
+		points := extractFromArray(arr, line, now, paths)
 		result.Points = points
 		return result
 	}
@@ -86,66 +106,181 @@ func parseJSON(line string) ParseResult {
 	return result
 }
 
-// extractFromMap extracts DataPoints from a JSON object.
-func extractFromMap(obj map[string]any, raw string) []stream.DataPoint {
+// extractFromMap extracts DataPoints from a JSON object. A timestamp field
+// (see timeFields, or paths.Time) sets the points' Timestamp and is not
+// treated as a value.
+//
+// With paths.Value set, the object yields exactly that value. Otherwise a
+// recognised value field (see valueFields) becomes a point named by the
+// label field, and every other number, however deeply nested, becomes a
+// point named by its dotted path (e.g. "http.latency.p99"). A recognised
+// label field is kept as a tag on every point (e.g. service=api), while a
+// label chosen with paths.Label prefixes the paths instead. Strings such as
+// "250ms" count only as the value field.
+func extractFromMap(obj map[string]any, raw string, now time.Time, paths JSONPaths) []stream.DataPoint {
 	var points []stream.DataPoint
 
-	valueFields := []string{"value", "val", "v", "metric", "count", "amount", "num"}
-	labelFields := []string{"label", "name", "key", "metric", "type", "service", "field"}
+	var ts time.Time
+	var tsField string
+	var hasTime bool
+	if paths.Time != nil {
+		if v, ok := paths.Time.Lookup(obj); ok {
+			ts, hasTime = parseTimeValue(v)
+		}
+		tsField = paths.Time.String()
+	} else {
+		ts, tsField, hasTime = findTimestamp(obj)
+	}
+	if !hasTime {
+		ts = now
+	}
+	var tags map[string]string
+	stamp := func(dp stream.DataPoint, unit string) stream.DataPoint {
+		dp.Timestamp = ts
+		dp.Raw = raw
+		dp.Unit = unit
+		dp.Tags = tags
+		return dp
+	}
 
-	var value float64
-	var label string
-	var foundValue bool
+	var label, labelField string
+	if paths.Label != nil {
+		if v, ok := paths.Label.Lookup(obj); ok {
+			label = labelString(v)
+		}
+		labelField = paths.Label.String()
+	} else {
+		for _, lf := range labelFields {
+			if l, ok := obj[lf]; ok {
+				if s, ok := l.(string); ok {
+					label, labelField = s, lf
+					tags = map[string]string{lf: s}
+					break
+				}
+			}
+		}
+	}
 
+	if paths.Value != nil {
+		v, ok := paths.Value.Lookup(obj)
+		if !ok {
+			return nil
+		}
+		f, unit, ok := toQuantity(v)
+		if !ok {
+			return nil
+		}
+		if paths.Label == nil {
+			label = paths.Value.String()
+		}
+		return []stream.DataPoint{stamp(stream.NewLabeledDataPoint(label, f), unit)}
+	}
+
+	valueField := ""
 	for _, vf := range valueFields {
+		if vf == tsField {
+			continue
+		}
 		if v, ok := obj[vf]; ok {
-			if f, ok := toFloat64(v); ok {
-				value = f
-				foundValue = true
+			if f, unit, ok := toQuantity(v); ok {
+				points = append(points, stamp(stream.NewLabeledDataPoint(label, f), unit))
+				valueField = vf
 				break
 			}
 		}
 	}
 
-	for _, lf := range labelFields {
-		if l, ok := obj[lf]; ok {
-			if s, ok := l.(string); ok {
-				label = s
-				break
-			}
+	prefix := ""
+	if paths.Label != nil && label != "" {
+		prefix = label + "."
+	}
+	flatten(obj, "", func(key string, f float64, unit string) {
+		if key == tsField || key == valueField || key == labelField {
+			return
+		}
+		points = append(points, stamp(stream.NewLabeledDataPoint(prefix+key, f), unit))
+	})
+
+	return points
+}
+
+// valueFields are JSON keys recognised as the value of a single metric, and
+// labelFields the keys naming it, in priority order.
+var (
+	valueFields = []string{"value", "val", "v", "metric", "count", "amount", "num"}
+	labelFields = []string{"label", "name", "key", "metric", "type", "service", "field"}
+)
+
+// flatten calls fn with the dotted path of every number under v, in key
+// order. Array elements are addressed by index ("items.0.ms").
+func flatten(v any, path string, fn func(key string, f float64, unit string)) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flatten(val[k], join(k), fn)
+		}
+	case []any:
+		for i, item := range val {
+			flatten(item, join(strconv.Itoa(i)), fn)
+		}
+	case string:
+		// Strings are IDs, versions and the like; they are values only when
+		// picked as the value field
+	default:
+		if f, unit, ok := toQuantity(val); ok && path != "" {
+			fn(path, f, unit)
 		}
 	}
+}
 
-	if foundValue {
-		dp := stream.NewLabeledDataPoint(label, value)
-		dp.Raw = raw
-		points = append(points, dp)
-		return points
+// labelString formats a JSON value used as a label.
+func labelString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
 	}
+	return ""
+}
 
-	for k, v := range obj {
-		if f, ok := toFloat64(v); ok {
-			dp := stream.NewLabeledDataPoint(k, f)
-			dp.Raw = raw
-			points = append(points, dp)
+// findTimestamp looks for a recognised timestamp field in a JSON object.
+func findTimestamp(obj map[string]any) (time.Time, string, bool) {
+	for _, field := range timeFields {
+		if v, ok := obj[field]; ok {
+			if t, ok := parseTimeValue(v); ok {
+				return t, field, true
+			}
 		}
 	}
-
-	return points
+	return time.Time{}, "", false
 }
 
 // extractFromArray extracts DataPoints from a JSON array.
-func extractFromArray(arr []any, raw string) []stream.DataPoint {
+func extractFromArray(arr []any, raw string, now time.Time, paths JSONPaths) []stream.DataPoint {
 	var points []stream.DataPoint
 
 	for _, item := range arr {
 		switch v := item.(type) {
 		case float64:
-			dp := stream.NewDataPoint(v)
+			dp := stream.NewDataPointAt(v, now)
 			dp.Raw = raw
 			points = append(points, dp)
 		case map[string]any:
-			subPoints := extractFromMap(v, raw)
+			subPoints := extractFromMap(v, raw, now, paths)
 			points = append(points, subPoints...)
 		}
 	}
@@ -153,65 +288,32 @@ func extractFromArray(arr []any, raw string) []stream.DataPoint {
 	return points
 }
 
-// toFloat64 attempts to convert an interface to float64.
-func toFloat64(v any) (float64, bool) {
+// toQuantity attempts to convert a decoded value to a number in its
+// canonical unit. Strings may carry a unit suffix, as in "250ms".
+func toQuantity(v any) (float64, string, bool) {
 	switch val := v.(type) {
 	case float64:
-		return val, true
+		return val, "", true
 	case int:
-		return float64(val), true
+		return float64(val), "", true
 	case int64:
-		return float64(val), true
+		return float64(val), "", true
+	case quantity:
+		return val.value, val.unit, true
 	case string:
-		if f, err := strconv.ParseFloat(val, 64); err == nil {
-			return f, true
-		}
+		return parseQuantity(val)
 	}
-	return 0, false
+	return 0, "", false
 }
 
-// parseCSV handles comma or tab separated values.
-func parseCSV(line string) ParseResult {
-	result := ParseResult{Format: FormatCSV}
-	line = strings.TrimSpace(line)
-
-	sep := ","
-	if strings.Contains(line, "\t") && !strings.Contains(line, ",") {
-		sep = "\t"
-	}
-
-	parts := strings.Split(line, sep)
-	if len(parts) == 0 {
-		return result
-	}
-
-	if len(parts) == 2 {
-		value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
-		if err == nil {
-			label := strings.TrimSpace(parts[0])
-			dp := stream.NewLabeledDataPoint(label, value)
-			dp.Raw = line
-			result.Points = []stream.DataPoint{dp}
-			return result
-		}
-	}
-
-	for i, part := range parts {
-		part = strings.TrimSpace(part)
-		if value, err := strconv.ParseFloat(part, 64); err == nil {
-			dp := stream.NewLabeledDataPoint(strconv.Itoa(i), value)
-			dp.Raw = line
-			result.Points = append(result.Points, dp)
-		}
-	}
-
-	return result
-}
-
-var numberPattern = regexp.MustCompile(`[-+]?[0-9]*\.?[0-9]+`)
+// numberPattern matches numbers in free text along with any suffix, which
+// is kept as a unit if it is one. Compound Go durations ("1m30s") are
+// matched whole.
+var numberPattern = regexp.MustCompile(
+	`(?:[-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:ns|us|µs|μs|ms|s|m|h)){2,}|[-+]?[0-9]*\.?[0-9]+[a-zA-Zµμ%]*`)
 
 // parseRaw extracts numeric values from arbitrary text.
-func parseRaw(line string) ParseResult {
+func parseRaw(line string, now time.Time) ParseResult {
 	result := ParseResult{Format: FormatRaw}
 	line = strings.TrimSpace(line)
 
@@ -219,19 +321,36 @@ func parseRaw(line string) ParseResult {
 		return result
 	}
 
-	if value, err := strconv.ParseFloat(line, 64); err == nil {
-		dp := stream.NewDataPoint(value)
-		dp.Raw = line
-		result.Points = []stream.DataPoint{dp}
+	// A leading timestamp is the event time, not a value
+	raw := line
+	ts, rest, hasTime := stripLeadingTimestamp(line)
+	line = rest
+	if !hasTime {
+		ts = now
+	}
+
+	newPoint := func(value float64, unit string) stream.DataPoint {
+		dp := stream.NewDataPointAt(value, ts)
+		dp.Raw = raw
+		dp.Unit = unit
+		return dp
+	}
+
+	if value, unit, ok := parseQuantity(line); ok {
+		result.Points = []stream.DataPoint{newPoint(value, unit)}
 		return result
 	}
 
 	matches := numberPattern.FindAllString(line, -1)
 	for _, match := range matches {
-		if value, err := strconv.ParseFloat(match, 64); err == nil {
-			dp := stream.NewDataPoint(value)
-			dp.Raw = line
-			result.Points = append(result.Points, dp)
+		if value, unit, ok := parseQuantity(match); ok {
+			result.Points = append(result.Points, newPoint(value, unit))
+			continue
+		}
+		// An unknown suffix, as in "100Mbps", leaves a plain number
+		number := strings.TrimRight(match, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZµμ%")
+		if value, err := strconv.ParseFloat(number, 64); err == nil {
+			result.Points = append(result.Points, newPoint(value, ""))
 		}
 	}
 
@@ -1,7 +1,11 @@
 package format
 
 import (
+	"fmt"
+	"math"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestDetect(t *testing.T) {
@@ -175,8 +179,8 @@ func TestAutoParse_Raw(t *testing.T) {
 		{
 			name:      "with units",
 			input:     "Response time: 45.2ms",
-			wantLen:   1, // "45.2" extracted
-			wantValue: 45.2,
+			wantLen:   1, // 45.2ms in seconds
+			wantValue: 0.0452,
 		},
 		{
 			name:    "empty",
@@ -198,9 +202,549 @@ func TestAutoParse_Raw(t *testing.T) {
 				return
 			}
 
-			if tt.wantLen == 1 && result.Points[0].Value != tt.wantValue {
+			if tt.wantLen == 1 && math.Abs(result.Points[0].Value-tt.wantValue) > 1e-12 {
 				t.Errorf("expected value %.5f, got %.5f", tt.wantValue, result.Points[0].Value)
 			}
 		})
 	}
 }
+
+func TestAutoParse_Timestamps(t *testing.T) {
+	want := time.Date(2024, 3, 1, 14, 2, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		input     string
+		wantLen   int
+		wantValue float64
+	}{
+		{"json ts rfc3339", `{"ts": "2024-03-01T14:02:00Z", "value": 5}`, 1, 5},
+		{"json @timestamp", `{"@timestamp": "2024-03-01T14:02:00Z", "latency": 5}`, 1, 5},
+		{"json epoch seconds", `{"time": 1709301720, "latency": 5}`, 1, 5},
+		{"json epoch millis", `{"timestamp": 1709301720000, "value": 5}`, 1, 5},
+		{"json epoch nanos", `{"ts": 1709301720000000000, "value": 5}`, 1, 5},
+		{"csv time column", "2024-03-01T14:02:00Z,cpu,5", 1, 5},
+		{"raw log prefix", "2024-03-01 14:02:00Z latency 5", 1, 5},
+		{"raw bracketed prefix", "[2024-03-01T14:02:00Z] took 5", 1, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := AutoParse(tt.input)
+			if len(result.Points) != tt.wantLen {
+				t.Fatalf("expected %d points, got %d: %+v", tt.wantLen, len(result.Points), result.Points)
+			}
+			p := result.Points[0]
+			if p.Value != tt.wantValue {
+				t.Errorf("expected value %.2f, got %.2f", tt.wantValue, p.Value)
+			}
+			if !p.Timestamp.Equal(want) {
+				t.Errorf("expected timestamp %v, got %v", want, p.Timestamp)
+			}
+		})
+	}
+}
+
+func TestAutoParse_LocalTimestamps(t *testing.T) {
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"2024-03-01 14:02:00 latency 5", time.Date(2024, 3, 1, 12, 2, 0, 0, time.UTC)},
+		{`{"ts": "2024-03-01T14:02:00.5", "value": 5}`, time.Date(2024, 3, 1, 12, 2, 0, 5e8, time.UTC)},
+		{`{"ts": "2024-03-01T14:02:00Z", "value": 5}`, time.Date(2024, 3, 1, 14, 2, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		points := AutoParse(tt.input).Points
+		if len(points) != 1 {
+			t.Fatalf("%s: expected 1 point, got %+v", tt.input, points)
+		}
+		if !points[0].Timestamp.Equal(tt.want) {
+			t.Errorf("%s: timestamp %v, want %v", tt.input, points[0].Timestamp, tt.want)
+		}
+	}
+}
+
+func TestParseJSON_Flatten(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]float64
+	}{
+		{
+			name:  "nested objects",
+			input: `{"http": {"latency": {"p50": 12, "p99": 80}}, "up": 1}`,
+			want:  map[string]float64{"http.latency.p50": 12, "http.latency.p99": 80, "up": 1},
+		},
+		{
+			name:  "arrays of objects",
+			input: `{"shards": [{"docs": 10}, {"docs": 20}]}`,
+			want:  map[string]float64{"shards.0.docs": 10, "shards.1.docs": 20},
+		},
+		{
+			name:  "value field keeps the other fields",
+			input: `{"service": "api", "count": 3, "timing": {"db_ms": 4}}`,
+			want:  map[string]float64{"api": 3, "timing.db_ms": 4},
+		},
+		{
+			name:  "strings are not values",
+			input: `{"user_id": "12345", "version": "2", "ttl": "2m", "req": {"bytes": 512}}`,
+			want:  map[string]float64{"req.bytes": 512},
+		},
+		{
+			name:  "timestamp is not a value",
+			input: `{"ts": 1709301720, "req": {"bytes": 512}}`,
+			want:  map[string]float64{"req.bytes": 512},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]float64)
+			for _, p := range AutoParse(tt.input).Points {
+				got[p.Label] = p.Value
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("points = %v, want %v", got, tt.want)
+			}
+			for label, v := range tt.want {
+				if got[label] != v {
+					t.Errorf("%s = %v, want %v (all: %v)", label, got[label], v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJSON_LabelFieldIsATag(t *testing.T) {
+	points := AutoParse(`{"service": "api", "latency": 3, "db": {"ms": 2}}`).Points
+	got := make(map[string]map[string]string)
+	for _, p := range points {
+		got[p.Label] = p.Tags
+	}
+	want := map[string]map[string]string{
+		"latency": {"service": "api"},
+		"db.ms":   {"service": "api"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labels and tags = %v, want %v", got, want)
+	}
+}
+
+func TestParser_JSONPaths(t *testing.T) {
+	line := `{"req": {"path": "/x", "at": "2024-03-01T14:02:00Z"}, "timing": {"db_ms": 3, "total_ms": 9}, "items": [{"ms": 7}]}`
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 1, 14, 2, 0, 0, time.UTC)
+
+	mustPath := func(s string) Path {
+		p, err := ParsePath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		paths  JSONPaths
+		want   map[string]float64
+		wantAt time.Time
+	}{
+		{
+			name:   "value path",
+			paths:  JSONPaths{Value: mustPath(".timing.db_ms")},
+			want:   map[string]float64{"timing.db_ms": 3},
+			wantAt: now,
+		},
+		{
+			name:   "value, label and time paths",
+			paths:  JSONPaths{Value: mustPath("timing.db_ms"), Label: mustPath("req.path"), Time: mustPath("$.req.at")},
+			want:   map[string]float64{"/x": 3},
+			wantAt: at,
+		},
+		{
+			name:   "array index",
+			paths:  JSONPaths{Value: mustPath("items[0].ms")},
+			want:   map[string]float64{"items.0.ms": 7},
+			wantAt: now,
+		},
+		{
+			name:   "label path prefixes flattened fields",
+			paths:  JSONPaths{Label: mustPath("req.path")},
+			want:   map[string]float64{"/x.timing.db_ms": 3, "/x.timing.total_ms": 9, "/x.items.0.ms": 7},
+			wantAt: now,
+		},
+		{
+			name:   "missing value path",
+			paths:  JSONPaths{Value: mustPath("timing.cache_ms")},
+			want:   map[string]float64{},
+			wantAt: now,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Parser{JSON: tt.paths}
+			got := make(map[string]float64)
+			for _, dp := range p.ParseAt(line, now).Points {
+				got[dp.Label] = dp.Value
+				if !dp.Timestamp.Equal(tt.wantAt) {
+					t.Errorf("%s at %v, want %v", dp.Label, dp.Timestamp, tt.wantAt)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("points = %v, want %v", got, tt.want)
+			}
+			for label, v := range tt.want {
+				if got[label] != v {
+					t.Errorf("%s = %v, want %v", label, got[label], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"a.b", "a.b", false},
+		{".a.b", "a.b", false},
+		{"$.a[2].b", "a.2.b", false},
+		{`tags["host.name"]`, "tags.host.name", false},
+		{"", "", true},
+		{"a[", "", true},
+		{"a[x]", "", true},
+		{`a[""]`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePath(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePath(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParsePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParser_CSV(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mustPath := func(s string) Path {
+		p, err := ParsePath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		parser Parser
+		lines  []string
+		want   []string // label=value, in order
+	}{
+		{
+			name:  "header names columns",
+			lines: []string{"cpu,mem", "5,70", "6,71"},
+			want:  []string{"cpu=5", "mem=70", "cpu=6", "mem=71"},
+		},
+		{
+			name:  "text column labels the row",
+			lines: []string{"host,cpu", "web1,5", "web2,7"},
+			want:  []string{"web1.cpu=5", "web2.cpu=7"},
+		},
+		{
+			name:  "quoted fields and escaped quotes",
+			lines: []string{`name,"p99, ms"`, `"GET /a, b",12`, `"say ""hi""",3`},
+			want:  []string{"GET /a, b.p99, ms=12", `say "hi".p99, ms=3`},
+		},
+		{
+			name:   "semicolon and decimal comma",
+			parser: Parser{CSV: CSVOptions{Delimiter: ';'}},
+			lines:  []string{"host;load", "web1;1,5", "web2;1.234,5"},
+			want:   []string{"web1.load=1.5", "web2.load=1234.5"},
+		},
+		{
+			name:   "detected semicolon",
+			parser: Parser{Format: FormatCSV},
+			lines:  []string{"a;b", "1,5;2"},
+			want:   []string{"a=1.5", "b=2"},
+		},
+		{
+			name:  "semicolon rows without commas",
+			lines: []string{"name;value", "cpu;15", "mem;1,5"},
+			want:  []string{"cpu=15", "mem=1.5"},
+		},
+		{
+			name:  "semicolon in text is not csv",
+			lines: []string{"retries 2; latency 5"},
+			want:  []string{"=2", "=5"},
+		},
+		{
+			name:  "tab separated",
+			lines: []string{"rps\tp50", "100\t3"},
+			want:  []string{"p50=3", "rps=100"},
+		},
+		{
+			name:  "tab separated without decimal comma",
+			lines: []string{"host\trps", "web1\t1.5", "web2\t1,234"},
+			want:  []string{"web1.rps=1.5"},
+		},
+		{
+			name:  "repeated header is not data",
+			lines: []string{"cpu,mem", "5,70", "cpu,mem", "6,71"},
+			want:  []string{"cpu=5", "mem=70", "cpu=6", "mem=71"},
+		},
+		{
+			name:  "text row after data is not a header",
+			lines: []string{"10,20,30", "a,b,c", "40,50,60"},
+			want:  []string{"0=10", "1=20", "2=30", "0=40", "1=50", "2=60"},
+		},
+		{
+			name:   "forced header",
+			parser: Parser{CSV: CSVOptions{Header: HeaderYes}},
+			lines:  []string{"2023,2024", "5,7"},
+			want:   []string{"2023=5", "2024=7"},
+		},
+		{
+			name:   "no header",
+			parser: Parser{CSV: CSVOptions{Header: HeaderNo}},
+			lines:  []string{"cpu,mem", "cpu,5"},
+			want:   []string{"cpu=5"},
+		},
+		{
+			name:   "label and value columns",
+			parser: Parser{JSON: JSONPaths{Label: mustPath("region"), Value: mustPath("p99")}},
+			lines:  []string{"host,region,p50,p99", "web1,eu,3,9"},
+			want:   []string{"eu=9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.parser
+			var got []string
+			for _, line := range tt.lines {
+				for _, dp := range p.ParseAt(line, now).Points {
+					got = append(got, fmt.Sprintf("%s=%v", dp.Label, dp.Value))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("points = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_CSVTimeColumn(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 1, 14, 2, 0, 0, time.UTC)
+	var p Parser
+	p.ParseAt("when,host,cpu", now)
+	points := p.ParseAt("2024-03-01T14:02:00Z,web1,5", now).Points
+	if len(points) != 1 || points[0].Label != "web1.cpu" || !points[0].Timestamp.Equal(at) {
+		t.Errorf("points = %+v, want web1.cpu at %v", points, at)
+	}
+
+	path, _ := ParsePath("epoch")
+	p = Parser{JSON: JSONPaths{Time: path}}
+	p.ParseAt("epoch,cpu", now)
+	points = p.ParseAt("1709301720,5", now).Points
+	if len(points) != 1 || points[0].Label != "cpu" || !points[0].Timestamp.Equal(at) {
+		t.Errorf("points = %+v, want cpu at %v", points, at)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		want    []string // label=value, in order
+		tags    map[string]string
+		at      time.Time
+	}{
+		{
+			name:    "named groups",
+			pattern: `(?P<method>\S+) (?P<label>\S+) (?P<status>\d+) (?P<value>\d+)ms`,
+			line:    "GET /api 200 34ms",
+			want:    []string{"/api=34"},
+			tags:    map[string]string{"method": "GET", "status": "200"},
+			at:      now,
+		},
+		{
+			name:    "no match",
+			pattern: `(?P<value>\d+)ms`,
+			line:    "took 3s",
+			at:      now,
+		},
+		{
+			name:    "nginx",
+			pattern: "nginx",
+			line:    `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif?x=1 HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08" 0.012`,
+			want:    []string{"bytes=2326", "request_time=0.012"},
+			tags:    map[string]string{"client": "127.0.0.1", "method": "GET", "path": "/a.gif", "status": "200"},
+			at:      time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+		},
+		{
+			name:    "apache common log without bytes",
+			pattern: "apache",
+			line:    `10.0.0.1 - - [10/Oct/2000:13:55:36 +0000] "HEAD / HTTP/1.1" 304 - 0.001`,
+			want:    []string{"request_time=0.001"},
+			tags:    map[string]string{"client": "10.0.0.1", "method": "HEAD", "path": "/", "status": "304"},
+			at:      time.Date(2000, 10, 10, 13, 55, 36, 0, time.UTC),
+		},
+		{
+			name:    "gobench",
+			pattern: "gobench",
+			line:    "BenchmarkParse-8   \t 1000000\t      1052 ns/op\t     320 B/op\t       5 allocs/op",
+			want:    []string{"BenchmarkParse.time_per_op=1.052e-06", "BenchmarkParse.bytes_per_op=320", "BenchmarkParse.allocs_per_op=5"},
+			at:      now,
+		},
+		{
+			name:    "ping",
+			pattern: "ping",
+			line:    "64 bytes from 1.1.1.1: icmp_seq=3 ttl=57 time=12.3 ms",
+			want:    []string{"rtt=0.0123"},
+			tags:    map[string]string{"host": "1.1.1.1", "seq": "3"},
+			at:      now,
+		},
+		{
+			name:    "iostat",
+			pattern: "iostat",
+			line:    "sda              1.50    2.25     40.00     80.00     0.00     1.00   0.00  30.77    0.53    1.20   0.00    26.67    35.56   0.40   3.10",
+			want:    []string{"sda.r_per_s=1.5", "sda.w_per_s=2.25", "sda.rkb_per_s=40", "sda.wkb_per_s=80", "sda.util=3.1"},
+			at:      now,
+		},
+		{
+			name:    "iostat header",
+			pattern: "iostat",
+			line:    "Device            r/s     w/s     rkB/s     wkB/s   rrqm/s   wrqm/s  %rrqm  %wrqm r_await w_await aqu-sz rareq-sz wareq-sz  svctm  %util",
+			at:      now,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pat, err := CompilePattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := Parser{Pattern: pat}
+			result := p.ParseAt(tt.line, now)
+			if result.Format != FormatPattern {
+				t.Errorf("format = %v, want pattern", result.Format)
+			}
+			var got []string
+			for _, dp := range result.Points {
+				got = append(got, fmt.Sprintf("%s=%v", dp.Label, dp.Value))
+				if !reflect.DeepEqual(dp.Tags, tt.tags) {
+					t.Errorf("%s tags = %v, want %v", dp.Label, dp.Tags, tt.tags)
+				}
+				if !dp.Timestamp.Equal(tt.at) {
+					t.Errorf("%s at %v, want %v", dp.Label, dp.Timestamp, tt.at)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("points = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePattern_Errors(t *testing.T) {
+	for _, expr := range []string{"nginxx", `(?P<label>\S+) (\d+)`, `(?P<value>\d+`} {
+		if _, err := CompilePattern(expr); err == nil {
+			t.Errorf("CompilePattern(%q) should fail", expr)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   float64
+		unit   string
+		wantOK bool
+	}{
+		{"42", 42, "", true},
+		{"250ms", 0.25, UnitSeconds, true},
+		{"1.5s", 1.5, UnitSeconds, true},
+		{"1.5 s", 1.5, UnitSeconds, true},
+		{"1m30s", 90, UnitSeconds, true},
+		{"2h", 7200, UnitSeconds, true},
+		{"512KiB", 512 * 1024, UnitBytes, true},
+		{"3.2GB", 3.2e9, UnitBytes, true},
+		{"45%", 45, UnitPercent, true},
+		{"100Mbps", 0, "", false},
+		{"ms", 0, "", false},
+		{"NaN", 0, "", false},
+		{"inf", 0, "", false},
+		{"-Infinity", 0, "", false},
+		{"1e308TB", 0, "", false},
+		{"fast", 0, "", false},
+	}
+	for _, tt := range tests {
+		got, unit, ok := parseQuantity(tt.in)
+		if ok != tt.wantOK || unit != tt.unit || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseQuantity(%q) = %v, %q, %v, want %v, %q, %v", tt.in, got, unit, ok, tt.want, tt.unit, tt.wantOK)
+		}
+	}
+}
+
+func TestAutoParse_Units(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string // label=value unit, in order
+	}{
+		{
+			name:  "mixed duration scales",
+			lines: []string{"latency 900ms", "latency 1.2s"},
+			want:  []string{"=0.9 s", "=1.2 s"},
+		},
+		{
+			name:  "unknown suffix stays a plain number",
+			lines: []string{"link 100Mbps up 3h"},
+			want:  []string{"=100 ", "=10800 s"},
+		},
+		{
+			name:  "go duration",
+			lines: []string{"took 1m30.5s"},
+			want:  []string{"=90.5 s"},
+		},
+		{
+			name:  "json value string",
+			lines: []string{`{"name": "db", "value": "250ms", "n": 3}`, `{"name": "heap", "value": "512KiB"}`},
+			want:  []string{"db=0.25 s", "n=3 ", "heap=524288 B"},
+		},
+		{
+			name:  "csv columns",
+			lines: []string{"name,size", "logs,3.2GB"},
+			want:  []string{"logs.size=3.2e+09 B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Parser
+			var got []string
+			for _, line := range tt.lines {
+				for _, dp := range p.ParseAt(line, time.Now()).Points {
+					got = append(got, fmt.Sprintf("%s=%v %s", dp.Label, dp.Value, dp.Unit))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("points = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
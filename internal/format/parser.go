@@ -0,0 +1,60 @@
+package format
+
+import (
+	"strings"
+	"time"
+)
+
+// Parser parses lines with settings that apply to a whole stream. The zero
+// Parser detects the format of each line, like AutoParse.
+//
+// A Parser remembers the header row of CSV input, so use one per stream.
+type Parser struct {
+	Format FormatType // format of every line ("" = detect per line)
+	JSON   JSONPaths  // fields to pick out of JSON objects, or CSV columns
+	CSV    CSVOptions
+	// Pattern parses lines instead of format detection, unless Format is set
+	Pattern *Pattern
+
+	csv csvState
+}
+
+// ParseAt parses a line, stamping points without a timestamp of their own
+// with now.
+func (p *Parser) ParseAt(line string, now time.Time) ParseResult {
+	if p.Pattern != nil && p.Format == "" {
+		return p.Pattern.parse(line, now)
+	}
+	format := p.Format
+	if format == "" {
+		format = Detect(line)
+		if format == FormatRaw && p.csvRow(line) {
+			format = FormatCSV
+		}
+	}
+	var result ParseResult
+	switch format {
+	case FormatJSON:
+		result = parseJSON(line, now, p.JSON)
+	case FormatCSV:
+		result = parseCSV(line, now, p.CSV, &p.csv, p.JSON)
+	default:
+		result = parseRaw(line, now)
+	}
+	result.Format = format
+	return result
+}
+
+// csvRow reports whether a line that Detect takes for raw text is a row of
+// the stream's CSV: it holds the configured or detected delimiter, or,
+// before the delimiter is known, it looks like a semicolon-separated row.
+func (p *Parser) csvRow(line string) bool {
+	delim := p.CSV.Delimiter
+	if delim == 0 {
+		delim = p.csv.comma
+	}
+	if delim != 0 {
+		return strings.ContainsRune(line, delim)
+	}
+	return semicolonRow(line)
+}
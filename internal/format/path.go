@@ -0,0 +1,104 @@
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPaths selects the value, label and timestamp of JSON objects
+// explicitly. Nil paths use the automatic rules.
+type JSONPaths struct {
+	Value Path
+	Label Path
+	Time  Path
+}
+
+// Path addresses a field inside a JSON document, written like jq or
+// JSONPath: "timing.db_ms", ".req.path", "$.items[0].ms" or
+// `tags["host.name"]`.
+type Path []pathElem
+
+type pathElem struct {
+	key   string
+	index int // used when key is empty
+}
+
+// ParsePath parses a path expression.
+func ParsePath(s string) (Path, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "$")
+	var path Path
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			continue
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", s)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				if len(inner) == 2 {
+					return nil, fmt.Errorf("invalid path %q: empty key", s)
+				}
+				path = append(path, pathElem{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid path %q: index %q", s, inner)
+			}
+			path = append(path, pathElem{index: i})
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			path = append(path, pathElem{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid path %q: empty", s)
+	}
+	return path, nil
+}
+
+// Lookup returns the value at the path in v, a decoded JSON document.
+func (p Path) Lookup(v any) (any, bool) {
+	for _, e := range p {
+		if e.key != "" {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[e.key]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		a, ok := v.([]any)
+		if !ok || e.index >= len(a) {
+			return nil, false
+		}
+		v = a[e.index]
+	}
+	return v, true
+}
+
+// String returns the path in the dotted form used for flattened labels,
+// such as "items.0.ms".
+func (p Path) String() string {
+	parts := make([]string, len(p))
+	for i, e := range p {
+		if e.key != "" {
+			parts[i] = e.key
+		} else {
+			parts[i] = strconv.Itoa(e.index)
+		}
+	}
+	return strings.Join(parts, ".")
+}
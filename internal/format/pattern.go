@@ -0,0 +1,153 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// Presets are named patterns for common tool output.
+var Presets = map[string]string{
+	// Common and combined log format, with an optional request time
+	// appended as nginx's $request_time often is.
+	"nginx":  combinedLog,
+	"apache": combinedLog,
+
+	// go test -bench output, e.g.
+	// "BenchmarkParse-8  1000000  1052 ns/op  320 B/op  5 allocs/op".
+	"gobench": `^(?P<label>Benchmark\S+?)(?:-\d+)?\s+\d+\s+(?P<value_time_per_op>[\d.]+ ns)/op` +
+		`(?:\s+(?P<value_mb_per_s>[\d.]+) MB/s)?(?:\s+(?P<value_bytes_per_op>\d+ B)/op)?` +
+		`(?:\s+(?P<value_allocs_per_op>\d+) allocs/op)?`,
+
+	// ping replies, e.g.
+	// "64 bytes from 1.1.1.1: icmp_seq=1 ttl=57 time=12.3 ms".
+	"ping": `bytes from (?P<host>[^:]+): icmp_seq=(?P<seq>\d+) ttl=\d+ time[=<](?P<value_rtt>[\d.]+ ms)`,
+
+	// iostat -x device rows, labelled by device. The first four columns are
+	// r/s, w/s, rkB/s and wkB/s and the last %util in every sysstat version.
+	"iostat": `^(?P<label>[a-z]\S*)\s+(?P<value_r_per_s>[\d.]+)\s+(?P<value_w_per_s>[\d.]+)` +
+		`\s+(?P<value_rkb_per_s>[\d.]+)\s+(?P<value_wkb_per_s>[\d.]+)\s.*\s(?P<value_util>[\d.]+)$`,
+}
+
+const combinedLog = `^(?P<client>\S+) \S+ \S+ \[(?P<time>[^\]]+)\] "(?P<method>[A-Z]+) (?P<path>[^ "?]+)[^"]*" ` +
+	`(?P<status>\d{3}) (?P<value_bytes>\d+|-)(?: "[^"]*" "[^"]*")?(?: (?P<value_request_time>[\d.]+))?`
+
+// Pattern extracts points from lines matching a regular expression, by the
+// names of its capture groups:
+//
+//	value         the value of a point named by label, with an optional
+//	              unit ("34ms", "2 KiB")
+//	value_<name>  the value of a point named <name>, or <label>.<name>
+//	label         the label
+//	time, ts      the event timestamp
+//	anything else a tag, e.g. status or path
+//
+// Lines that do not match yield no points.
+type Pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// CompilePattern compiles a preset name or a regular expression, which must
+// have a value or value_<name> group.
+func CompilePattern(expr string) (*Pattern, error) {
+	name := expr
+	if preset, ok := Presets[expr]; ok {
+		expr = preset
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	for _, g := range re.SubexpNames() {
+		if g == "value" || strings.HasPrefix(g, "value_") {
+			return &Pattern{name: name, re: re}, nil
+		}
+	}
+	if !strings.ContainsAny(expr, `\()[]{}.*+?^$|`) {
+		return nil, fmt.Errorf("unknown pattern preset %q, expected one of %s", expr, strings.Join(PresetNames(), ", "))
+	}
+	return nil, fmt.Errorf("pattern %q has no value or value_<name> group", expr)
+}
+
+// PresetNames returns the names of the presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the preset name or expression the pattern was compiled
+// from.
+func (p *Pattern) String() string {
+	return p.name
+}
+
+// parse extracts the points of a line that matches the pattern.
+func (p *Pattern) parse(line string, now time.Time) ParseResult {
+	result := ParseResult{Format: FormatPattern}
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return result
+	}
+
+	ts := now
+	var label string
+	var tags map[string]string
+	type value struct{ name, text string }
+	var values []value
+	for i, g := range p.re.SubexpNames() {
+		if g == "" || i >= len(m) || m[i] == "" {
+			continue
+		}
+		switch {
+		case g == "value":
+			values = append(values, value{text: m[i]})
+		case strings.HasPrefix(g, "value_"):
+			values = append(values, value{name: strings.TrimPrefix(g, "value_"), text: m[i]})
+		case g == "label":
+			label = m[i]
+		case g == "time" || g == "ts" || g == "timestamp":
+			if t, ok := parseTimeString(m[i]); ok {
+				ts = t
+			}
+		default:
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[g] = m[i]
+		}
+	}
+
+	for _, v := range values {
+		f, unit, ok := parseQuantity(v.text)
+		if !ok {
+			continue
+		}
+		l := label
+		if v.name != "" {
+			l = joinLabel(label, v.name)
+		}
+		dp := stream.NewLabeledDataPointAt(l, f, ts)
+		dp.Raw = line
+		dp.Unit = unit
+		dp.Tags = tags
+		result.Points = append(result.Points, dp)
+	}
+	return result
+}
+
+// joinLabel prefixes name with label, if there is one.
+func joinLabel(label, name string) string {
+	if label == "" {
+		return name
+	}
+	return label + "." + name
+}
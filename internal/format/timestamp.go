@@ -0,0 +1,110 @@
+package format
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFields are JSON keys recognised as the event timestamp, in priority order.
+var timeFields = []string{"@timestamp", "timestamp", "time", "ts"}
+
+// timeLayouts are the string formats tried when parsing timestamps.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC1123Z,
+	time.RFC1123,
+	"02/Jan/2006:15:04:05 -0700", // common and combined log format
+}
+
+// leadingTimestamp matches an ISO 8601 style timestamp at the start of a
+// log line, optionally wrapped in brackets.
+var leadingTimestamp = regexp.MustCompile(
+	`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)\]?\s*`)
+
+// parseTimeString parses a timestamp written as a date string or as epoch
+// seconds, milliseconds, microseconds or nanoseconds.
+func parseTimeString(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return parseEpoch(f)
+	}
+
+	// Timestamps without a zone are local time, as logs usually are
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimeValue parses a decoded JSON value as a timestamp.
+func parseTimeValue(v any) (time.Time, bool) {
+	switch val := v.(type) {
+	case float64:
+		return parseEpoch(val)
+	case string:
+		return parseTimeString(val)
+	}
+	return time.Time{}, false
+}
+
+// parseEpoch interprets a number as a Unix timestamp, guessing the unit from
+// its magnitude. Values that are too small to be a plausible date (before
+// 1973 in seconds) are rejected.
+func parseEpoch(f float64) (time.Time, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+
+	switch abs := math.Abs(f); {
+	case abs < 1e8:
+		return time.Time{}, false
+	case abs < 1e11: // seconds
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	case abs < 1e14: // milliseconds
+		return time.UnixMilli(int64(f)), true
+	case abs < 1e17: // microseconds
+		return time.UnixMicro(int64(f)), true
+	default: // nanoseconds
+		return time.Unix(0, int64(f)), true
+	}
+}
+
+// isDateString reports whether s is a date string (not a bare number) that
+// parses as a timestamp. Used for CSV columns, where bare numbers are values.
+func isDateString(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Time{}, false
+	}
+	return parseTimeString(s)
+}
+
+// stripLeadingTimestamp removes a timestamp prefix from a log line and
+// returns it along with the rest of the line.
+func stripLeadingTimestamp(line string) (time.Time, string, bool) {
+	m := leadingTimestamp.FindStringSubmatchIndex(line)
+	if m == nil {
+		return time.Time{}, line, false
+	}
+
+	t, ok := parseTimeString(strings.Replace(line[m[2]:m[3]], ",", ".", 1))
+	if !ok {
+		return time.Time{}, line, false
+	}
+	return t, line[m[1]:], true
+}
@@ -0,0 +1,89 @@
+package format
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Canonical units carried on DataPoint.Unit. Durations are converted to
+// seconds and sizes to bytes, so "900ms" and "1.2s" land on one scale.
+const (
+	UnitSeconds = "s"
+	UnitBytes   = "B"
+	UnitPercent = "%"
+)
+
+// unitScales maps unit suffixes to their canonical unit and scale.
+var unitScales = map[string]struct {
+	unit  string
+	scale float64
+}{
+	"ns":  {UnitSeconds, 1e-9},
+	"us":  {UnitSeconds, 1e-6},
+	"µs":  {UnitSeconds, 1e-6},
+	"μs":  {UnitSeconds, 1e-6},
+	"ms":  {UnitSeconds, 1e-3},
+	"s":   {UnitSeconds, 1},
+	"m":   {UnitSeconds, 60},
+	"min": {UnitSeconds, 60},
+	"h":   {UnitSeconds, 3600},
+
+	"B":   {UnitBytes, 1},
+	"kB":  {UnitBytes, 1e3},
+	"KB":  {UnitBytes, 1e3},
+	"MB":  {UnitBytes, 1e6},
+	"GB":  {UnitBytes, 1e9},
+	"TB":  {UnitBytes, 1e12},
+	"PB":  {UnitBytes, 1e15},
+	"KiB": {UnitBytes, 1 << 10},
+	"MiB": {UnitBytes, 1 << 20},
+	"GiB": {UnitBytes, 1 << 30},
+	"TiB": {UnitBytes, 1 << 40},
+	"PiB": {UnitBytes, 1 << 50},
+
+	"%": {UnitPercent, 1},
+}
+
+// quantityPattern splits a number from its unit suffix.
+var quantityPattern = regexp.MustCompile(`^([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][-+]?[0-9]+)?) ?(\S+)$`)
+
+// parseQuantity parses a number with an optional unit suffix, such as
+// "250ms", "1.5 s", "512KiB", "45%" or a Go duration like "1m30s", and
+// returns it in the canonical unit. NaN and infinities are not values, as
+// they would poison every statistic of the window they land in.
+func parseQuantity(s string) (float64, string, bool) {
+	s = strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, "", false
+		}
+		return f, "", true
+	}
+	m := quantityPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, "", false
+	}
+	if u, ok := unitScales[m[2]]; ok {
+		f, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, "", false
+		}
+		if math.IsInf(f*u.scale, 0) {
+			return 0, "", false
+		}
+		return f * u.scale, u.unit, true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d.Seconds(), UnitSeconds, true
+	}
+	return 0, "", false
+}
+
+// quantity is a number with a canonical unit, as decoded from a CSV field.
+type quantity struct {
+	value float64
+	unit  string
+}
@@ -1,10 +1,17 @@
 package layout
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/danqzq/rift/internal/chart"
 	"github.com/danqzq/rift/internal/stream"
 )
 
+// DefaultNoData is shown in regions whose window is empty.
+const DefaultNoData = "no data"
+
 // Region represents a rectangular area in the terminal.
 type Region struct {
 	X      int
@@ -15,6 +22,19 @@ type Region struct {
 	Chart  chart.Chart
 	Window *stream.Window
 	Label  string
+
+	StaleAfter time.Duration // mark the region stale after this long without points (0 disables)
+	NoData     string        // shown when the window is empty (defaults to DefaultNoData)
+
+	Banner      string // shown on the top row, e.g. a firing alert ("" hides it)
+	BannerColor string // background color of the banner: red, yellow, ...
+	Footer      string // shown dimmed on the bottom row, e.g. anomaly counts ("" hides it)
+}
+
+// bannerColors are ANSI background codes for Region.BannerColor.
+var bannerColors = map[string]int{
+	"black": 40, "red": 41, "green": 42, "yellow": 43,
+	"blue": 44, "magenta": 45, "cyan": 46, "white": 47,
 }
 
 // NewRegion creates a new region with the specified dimensions.
@@ -26,3 +46,78 @@ func NewRegion(x, y, width, height int) *Region {
 		Height: height,
 	}
 }
+
+// Age returns how long ago the region's window last received a point, and
+// false if it never has.
+func (r *Region) Age(now time.Time) (time.Duration, bool) {
+	last := r.Window.LastAdd()
+	if last.IsZero() {
+		return 0, false
+	}
+	return now.Sub(last), true
+}
+
+// Content renders the region's contents between its banner and footer, if
+// any.
+func (r *Region) Content(now time.Time) string {
+	height := r.Height
+	footer := ""
+	if r.Footer != "" && height >= 2 {
+		height--
+		footer = "\n\033[2m" + truncateRunes(r.Footer, r.Width) + "\033[0m"
+	}
+	if r.Banner == "" || height < 2 {
+		return r.body(now, height) + footer
+	}
+
+	banner := []rune(" " + r.Banner)
+	if len(banner) > r.Width {
+		banner = banner[:r.Width]
+	}
+	line := string(banner) + strings.Repeat(" ", r.Width-len(banner))
+	if code, ok := bannerColors[r.BannerColor]; ok {
+		line = fmt.Sprintf("\033[1;%dm%s\033[0m", code, line)
+	} else {
+		line = "\033[7m" + line + "\033[0m"
+	}
+	return line + "\n" + r.body(now, height-1) + footer
+}
+
+// truncateRunes shortens s to at most width runes.
+func truncateRunes(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:max(width, 0)])
+	}
+	return s
+}
+
+// body renders the region's chart in height rows, replacing it with the
+// no-data text when the window is empty and dimming it with a status line
+// when stale.
+func (r *Region) body(now time.Time, height int) string {
+	if r.Window.Len() == 0 {
+		noData := r.NoData
+		if noData == "" {
+			noData = DefaultNoData
+		}
+		if r.Label != "" {
+			return fmt.Sprintf("%s: %s", r.Label, noData)
+		}
+		return noData
+	}
+
+	age, ok := r.Age(now)
+	if r.StaleAfter <= 0 || !ok || age < r.StaleAfter || height < 2 {
+		return r.Chart.Render(r.Window, r.Width, height)
+	}
+
+	// Stale: dim the last known data and explain why on the last row
+	content := r.Chart.Render(r.Window, r.Width, height-1)
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = "\033[2m" + line + "\033[0m"
+	}
+	status := fmt.Sprintf("stale: no data for %s", age.Truncate(time.Second))
+	lines = append(lines, "\033[33m"+status+"\033[0m")
+	return strings.Join(lines, "\n")
+}
@@ -3,14 +3,22 @@ package layout
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"golang.org/x/term"
+
+	"github.com/danqzq/rift/internal/clock"
 )
 
 // Renderer handles full-screen terminal rendering.
 type Renderer struct {
 	regions []*Region
+
+	// Clock decides how stale each region is (nil uses the system clock)
+	Clock clock.Clock
+
+	bell bool
 }
 
 // NewRenderer creates a new terminal renderer.
@@ -42,16 +50,49 @@ func (r *Renderer) Render() {
 			continue
 		}
 
-		// Render the chart
-		content := region.Chart.Render(region.Window, region.Width, region.Height)
+		// Drop points that aged out while the stream was quiet, then render
+		region.Window.Expire()
+		content := region.Content(clock.Or(r.Clock).Now())
 
 		// Position cursor and write content
 		r.writeAt(region.X, region.Y, content)
 	}
 
+	if r.bell {
+		fmt.Print("\a")
+		r.bell = false
+	}
+
 	// TODO: improve later with proper cursor management
 }
 
+// Bell rings the terminal bell with the next Render.
+func (r *Renderer) Bell() {
+	r.bell = true
+}
+
+// colorCode matches the ANSI escape sequences that set text color and style.
+var colorCode = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Frame renders the regions top to bottom as plain lines, each padded to
+// its height, for writing to a file or pipe instead of the terminal. Color
+// codes, as on alert banners, are left out.
+func (r *Renderer) Frame() string {
+	var lines []string
+	for _, region := range r.regions {
+		if region.Chart == nil || region.Window == nil {
+			continue
+		}
+		region.Window.Expire()
+		content := strings.Split(colorCode.ReplaceAllString(region.Content(clock.Or(r.Clock).Now()), ""), "\n")
+		for len(content) < region.Height {
+			content = append(content, "")
+		}
+		lines = append(lines, content...)
+	}
+	return strings.Join(lines, "\n")
+}
+
 // writeAt positions the cursor and writes content.
 func (r *Renderer) writeAt(x, y int, content string) {
 	lines := strings.Split(content, "\n")
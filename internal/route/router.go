@@ -1,8 +1,10 @@
 package route
 
 import (
+	"github.com/danqzq/rift/internal/analysis"
 	"github.com/danqzq/rift/internal/chart"
 	"github.com/danqzq/rift/internal/stream"
+	"github.com/danqzq/rift/internal/transform"
 )
 
 // Route defines a routing rule from selector to chart.
@@ -11,6 +13,8 @@ type Route struct {
 	ChartType string
 	Chart     chart.Chart
 	Window    *stream.Window
+	Transform transform.Pipeline // applied to matching points before they are added
+	Detector  *analysis.Detector // flags anomalies after the transform, if set
 }
 
 // Router manages multiple routes and dispatches data points.
@@ -34,10 +38,18 @@ func (r *Router) AddRoute(route *Route) {
 func (r *Router) Route(p stream.DataPoint) int {
 	matched := 0
 	for _, route := range r.routes {
-		if route.Selector.Matches(p) {
-			route.Window.Add(p)
-			matched++
+		if !route.Selector.Matches(p) {
+			continue
 		}
+		matched++
+		tp, ok := route.Transform.Apply(p)
+		if !ok {
+			continue
+		}
+		if route.Detector != nil {
+			tp = route.Detector.Observe(tp)
+		}
+		route.Window.Add(tp)
 	}
 	return matched
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/danqzq/rift/internal/stream"
+	"github.com/danqzq/rift/internal/transform"
 )
 
 func TestFieldSelector_Matches(t *testing.T) {
@@ -25,6 +26,24 @@ func TestFieldSelector_Matches(t *testing.T) {
 			point:    stream.NewLabeledDataPoint("cpu", 45),
 			want:     false,
 		},
+		{
+			name:     "matches source",
+			selector: NewFieldSelector("source", "db"),
+			point:    stream.DataPoint{Label: "latency", Source: "db"},
+			want:     true,
+		},
+		{
+			name:     "matches tag",
+			selector: NewFieldSelector("status", "500"),
+			point:    stream.DataPoint{Label: "/api", Tags: map[string]string{"status": "500"}},
+			want:     true,
+		},
+		{
+			name:     "missing tag",
+			selector: NewFieldSelector("status", ""),
+			point:    stream.DataPoint{Label: "/api"},
+			want:     false,
+		},
 		{
 			name:     "matches metric field",
 			selector: NewFieldSelector("metric", "latency"),
@@ -62,6 +81,8 @@ func TestParseSelector(t *testing.T) {
 		{"star", "*", "*"},
 		{"field equals", "metric=cpu", "metric=cpu"},
 		{"label implicit", "cpu", "label=cpu"},
+		{"source", "source=db", "source=db"},
+		{"all", "source=db, latency", "source=db,label=latency"},
 	}
 
 	for _, tt := range tests {
@@ -120,3 +141,27 @@ func TestRouter_Route(t *testing.T) {
 		t.Errorf("w2 should have 1 point, got %d", w2.Len())
 	}
 }
+
+func TestRouter_Transform(t *testing.T) {
+	pl, err := transform.Parse("delta|scale(10)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := stream.NewFixedWindow(10)
+	router := NewRouter()
+	router.AddRoute(&Route{Selector: NewFieldSelector("label", "cpu"), Window: w, Transform: pl})
+
+	for _, v := range []float64{1, 3, 4} {
+		if n := router.Route(stream.NewLabeledDataPoint("cpu", v)); n != 1 {
+			t.Fatalf("Route() = %d, want 1", n)
+		}
+	}
+
+	var got []float64
+	for _, p := range w.Points() {
+		got = append(got, p.Value)
+	}
+	if len(got) != 2 || got[0] != 20 || got[1] != 10 {
+		t.Errorf("window values = %v, want [20 10]", got)
+	}
+}
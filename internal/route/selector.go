@@ -14,7 +14,7 @@ type Selector interface {
 
 // FieldSelector matches based on a field value.
 type FieldSelector struct {
-	Field string // field name to match (e.g., "metric", "label")
+	Field string // field name to match (e.g., "label", "source" or a tag)
 	Value string // expected value
 }
 
@@ -26,13 +26,17 @@ func NewFieldSelector(field, value string) *FieldSelector {
 	}
 }
 
-// Matches checks if the data point's label matches the expected value.
+// Matches checks if the data point's label, source or tag matches the
+// expected value.
 func (f *FieldSelector) Matches(p stream.DataPoint) bool {
 	switch f.Field {
 	case "label", "metric", "name", "key":
 		return p.Label == f.Value
+	case "source":
+		return p.Source == f.Value
 	default:
-		return false
+		v, ok := p.Tags[f.Field]
+		return ok && v == f.Value
 	}
 }
 
@@ -54,13 +58,44 @@ func (a *AlwaysSelector) String() string {
 	return "*"
 }
 
+// AllSelector matches data points that match every one of its selectors.
+type AllSelector []Selector
+
+// Matches reports whether all selectors match.
+func (a AllSelector) Matches(p stream.DataPoint) bool {
+	for _, s := range a {
+		if !s.Matches(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the selectors joined by commas.
+func (a AllSelector) String() string {
+	parts := make([]string, len(a))
+	for i, s := range a {
+		parts[i] = s.String()
+	}
+	return strings.Join(parts, ",")
+}
+
 // ParseSelector parses a selector expression.
 // Supported formats:
 // - "field=value" -> FieldSelector
 // - "*" -> AlwaysSelector
+// - "source=db,latency" -> AllSelector of the comma-separated parts
 func ParseSelector(expr string) Selector {
 	expr = strings.TrimSpace(expr)
 
+	if parts := strings.Split(expr, ","); len(parts) > 1 {
+		all := make(AllSelector, len(parts))
+		for i, part := range parts {
+			all[i] = ParseSelector(part)
+		}
+		return all
+	}
+
 	if expr == "" || expr == "*" {
 		return &AlwaysSelector{}
 	}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSpeed parses a playback speed such as "10x", "0.5" or "max" (as fast
+// as possible, returned as +Inf).
+func ParseSpeed(s string) (float64, error) {
+	norm := strings.ToLower(strings.TrimSpace(s))
+	if norm == "max" {
+		return math.Inf(1), nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(norm, "x"), 64)
+	if err != nil || v <= 0 || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid speed %q, expected e.g. 10x, 0.5x or max", s)
+	}
+	return v, nil
+}
+
+// Player steps through records on a session clock. The clock starts at the
+// first record and advances by wall-clock time multiplied by the speed
+// while not paused. Player does no I/O and keeps no goroutines: the caller
+// drives it with Advance and acts on the records it returns.
+type Player struct {
+	records []Record
+	speed   float64
+	paused  bool
+
+	next int           // index of the next record to play
+	pos  time.Duration // session clock, relative to the first record
+}
+
+// NewPlayer creates a player at the start of the session.
+func NewPlayer(records []Record, speed float64) *Player {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &Player{records: records, speed: speed}
+}
+
+// offset returns the session time of record i.
+func (p *Player) offset(i int) time.Duration {
+	return p.records[i].Time.Sub(p.records[0].Time)
+}
+
+// Advance moves the clock forward by elapsed wall-clock time and returns the
+// records that became due. It returns nothing while paused.
+func (p *Player) Advance(elapsed time.Duration) []Record {
+	if p.paused || p.Done() {
+		return nil
+	}
+	if math.IsInf(p.speed, 1) {
+		return p.playUntil(p.Duration())
+	}
+	return p.playUntil(p.pos + time.Duration(float64(elapsed)*p.speed))
+}
+
+// playUntil moves the clock to pos and returns records due by then.
+func (p *Player) playUntil(pos time.Duration) []Record {
+	start := p.next
+	for p.next < len(p.records) && p.offset(p.next) <= pos {
+		p.next++
+	}
+	p.pos = max(pos, 0)
+	return p.records[start:p.next]
+}
+
+// Step plays exactly the next record, moving the clock to its time, and
+// pauses playback.
+func (p *Player) Step() []Record {
+	p.paused = true
+	if p.Done() {
+		return nil
+	}
+	p.pos = p.offset(p.next)
+	p.next++
+	return p.records[p.next-1 : p.next]
+}
+
+// Seek moves the clock to pos (clamped to the session) and returns the
+// records to replay. Seeking forward returns the records skipped over.
+// Seeking backward returns reset=true along with every record from the
+// start: the caller must discard its state and replay them.
+func (p *Player) Seek(pos time.Duration) (records []Record, reset bool) {
+	pos = max(0, min(pos, p.Duration()))
+	if pos < p.pos {
+		p.next, p.pos = 0, 0
+		reset = true
+	}
+	return p.playUntil(pos), reset
+}
+
+// TogglePause pauses or resumes playback and reports whether it is now paused.
+func (p *Player) TogglePause() bool {
+	p.paused = !p.paused
+	return p.paused
+}
+
+// Paused reports whether playback is paused.
+func (p *Player) Paused() bool {
+	return p.paused
+}
+
+// Speed returns the playback speed.
+func (p *Player) Speed() float64 {
+	return p.speed
+}
+
+// SetSpeed changes the playback speed.
+func (p *Player) SetSpeed(speed float64) {
+	if speed > 0 {
+		p.speed = speed
+	}
+}
+
+// Position returns the session clock relative to the first record.
+func (p *Player) Position() time.Duration {
+	return p.pos
+}
+
+// Now returns the session clock as the recorded wall-clock time.
+func (p *Player) Now() time.Time {
+	if len(p.records) == 0 {
+		return time.Time{}
+	}
+	return p.records[0].Time.Add(p.pos)
+}
+
+// Duration returns the time between the first and last records.
+func (p *Player) Duration() time.Duration {
+	if len(p.records) == 0 {
+		return 0
+	}
+	return p.offset(len(p.records) - 1)
+}
+
+// Done reports whether every record has been played.
+func (p *Player) Done() bool {
+	return p.next >= len(p.records)
+}
@@ -0,0 +1,80 @@
+// Package session records input lines with their arrival times and plays
+// them back with the original timing.
+//
+// A session file is JSON lines: a header object followed by one Record per
+// input line.
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Version is the session file format version.
+const Version = 1
+
+// header is the first line of a session file.
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+const formatName = "rift-session"
+
+// Record is one recorded input line.
+type Record struct {
+	Time   time.Time `json:"t"`
+	Source string    `json:"src,omitempty"`
+	Line   string    `json:"line"`
+	Format string    `json:"format,omitempty"` // format the source forced, if any
+}
+
+// Writer writes a session file.
+type Writer struct {
+	enc *json.Encoder
+}
+
+// NewWriter writes the session header to w and returns a writer for records.
+func NewWriter(w io.Writer) (*Writer, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(header{Format: formatName, Version: Version}); err != nil {
+		return nil, err
+	}
+	return &Writer{enc: enc}, nil
+}
+
+// Write appends a record.
+func (w *Writer) Write(r Record) error {
+	return w.enc.Encode(r)
+}
+
+// ReadAll reads a whole session file.
+func ReadAll(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+
+	var h header
+	if err := dec.Decode(&h); err != nil || h.Format != formatName {
+		return nil, fmt.Errorf("not a rift session file")
+	}
+	if h.Version > Version {
+		return nil, fmt.Errorf("session file version %d is newer than supported version %d", h.Version, Version)
+	}
+
+	var records []Record
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
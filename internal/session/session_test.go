@@ -0,0 +1,139 @@
+package session
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+
+// records returns one record per offset in seconds.
+func records(offsets ...float64) []Record {
+	var recs []Record
+	for i, o := range offsets {
+		recs = append(recs, Record{
+			Time: start.Add(time.Duration(o * float64(time.Second))),
+			Line: strings.Repeat("x", i+1),
+		})
+	}
+	return recs
+}
+
+func lines(recs []Record) []string {
+	var out []string
+	for _, r := range recs {
+		out = append(out, r.Line)
+	}
+	return out
+}
+
+func TestWriteReadAll(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Time: start, Source: "db", Line: `{"latency": 12, "path": "/a<b>"}`},
+		{Time: start.Add(time.Second), Line: "cpu,45"},
+		{Time: start.Add(2 * time.Second), Source: "du", Line: "42 /var", Format: "raw"},
+	}
+	for _, r := range want {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ReadAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Source != want[i].Source || got[i].Line != want[i].Line ||
+			got[i].Format != want[i].Format {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := ReadAll(strings.NewReader("cpu,45\n")); err == nil {
+		t.Error("ReadAll should reject a file without a session header")
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	tests := map[string]float64{"10x": 10, "0.5": 0.5, "1X": 1, "max": math.Inf(1)}
+	for s, want := range tests {
+		if got, err := ParseSpeed(s); err != nil || got != want {
+			t.Errorf("ParseSpeed(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0", "-2x", "fast"} {
+		if _, err := ParseSpeed(s); err == nil {
+			t.Errorf("ParseSpeed(%q) should fail", s)
+		}
+	}
+}
+
+func TestPlayer_Advance(t *testing.T) {
+	p := NewPlayer(records(0, 1, 5, 10), 10)
+
+	if got := lines(p.Advance(0)); len(got) != 1 {
+		t.Errorf("first record should be due immediately, got %v", got)
+	}
+	// 100ms at 10x is 1s of session time
+	if got := lines(p.Advance(100 * time.Millisecond)); len(got) != 1 || got[0] != "xx" {
+		t.Errorf("got %v, want [xx]", got)
+	}
+
+	p.TogglePause()
+	if got := p.Advance(time.Hour); len(got) != 0 {
+		t.Errorf("paused player returned %v", lines(got))
+	}
+	p.TogglePause()
+
+	if got := lines(p.Advance(time.Second)); len(got) != 2 || !p.Done() {
+		t.Errorf("got %v, done=%v; want the last two records", got, p.Done())
+	}
+	if !p.Now().Equal(start.Add(11 * time.Second)) {
+		t.Errorf("Now() = %v", p.Now())
+	}
+}
+
+func TestPlayer_StepAndSeek(t *testing.T) {
+	p := NewPlayer(records(0, 1, 5, 10), 1)
+
+	if got := lines(p.Step()); len(got) != 1 || got[0] != "x" || !p.Paused() {
+		t.Errorf("Step() = %v, paused=%v", got, p.Paused())
+	}
+	if got := lines(p.Step()); len(got) != 1 || got[0] != "xx" || p.Position() != time.Second {
+		t.Errorf("Step() = %v at %v", got, p.Position())
+	}
+
+	recs, reset := p.Seek(6 * time.Second)
+	if got := lines(recs); reset || len(got) != 1 || got[0] != "xxx" {
+		t.Errorf("Seek forward = %v, reset=%v; want [xxx]", got, reset)
+	}
+
+	recs, reset = p.Seek(2 * time.Second)
+	if got := lines(recs); !reset || len(got) != 2 {
+		t.Errorf("Seek backward = %v, reset=%v; want the first two records and a reset", got, reset)
+	}
+
+	recs, _ = p.Seek(time.Hour)
+	if p.Position() != 10*time.Second || !p.Done() || len(recs) != 2 {
+		t.Errorf("Seek past the end: position %v, done=%v, %d records", p.Position(), p.Done(), len(recs))
+	}
+}
+
+func TestPlayer_MaxSpeed(t *testing.T) {
+	p := NewPlayer(records(0, 3600, 7200), math.Inf(1))
+	if got := p.Advance(0); len(got) != 3 || !p.Done() {
+		t.Errorf("max speed should play everything at once, got %d records", len(got))
+	}
+}
@@ -0,0 +1,135 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// poller runs a command every interval and emits its output lines stamped
+// with the time the run started. Runs are killed after the timeout, which
+// defaults to the interval. With a longer timeout, a run still going when
+// the next one is due makes that poll be skipped rather than piling up runs.
+type poller struct {
+	spec   Spec
+	config stream.ReaderConfig
+	in     *Input
+
+	mu    sync.Mutex
+	stats stream.ReaderStats
+}
+
+func (p *poller) run(ctx context.Context) {
+	ticker := p.in.clock.NewTicker(p.spec.Interval)
+	defer ticker.Stop()
+
+	var running sync.WaitGroup
+	defer running.Wait()
+	busy := make(chan struct{}, 1)
+
+	for {
+		select {
+		case busy <- struct{}{}:
+			running.Add(1)
+			go func(at time.Time) {
+				defer running.Done()
+				defer func() { <-busy }()
+				p.poll(ctx, at)
+			}(p.in.clock.Now())
+		default:
+			// Without a longer timeout the run is about to time out, which
+			// is reported on its own
+			if p.spec.Timeout > p.spec.Interval {
+				p.in.report(ctx, fmt.Errorf("source %s: previous run still going after %s, skipping this poll",
+					p.spec.Name, p.spec.Interval))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// errTimedOut cancels a run that outlives its timeout.
+var errTimedOut = errors.New("timed out")
+
+// poll runs the command once and emits its output.
+func (p *poller) poll(ctx context.Context, at time.Time) {
+	timeout := p.spec.Timeout
+	if timeout <= 0 {
+		timeout = p.spec.Interval
+	}
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	deadline := p.in.clock.NewTicker(timeout)
+	defer deadline.Stop()
+	go func() {
+		select {
+		case <-deadline.C():
+			cancel(errTimedOut)
+		case <-runCtx.Done():
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "sh", "-c", p.spec.Target)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // don't wait on children holding stdout after a kill
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return
+	}
+	if errors.Is(context.Cause(runCtx), errTimedOut) {
+		p.in.report(ctx, fmt.Errorf("source %s: timed out after %s", p.spec.Name, timeout))
+		return
+	}
+
+	// Emit the output even when the command fails: grep -c exits 1 on zero
+	// matches but still prints the count
+	lr := stream.NewLineReaderConfig(ctx, &stdout, stream.ReaderConfig{
+		MaxLine:   p.config.MaxLine,
+		LongLines: p.config.LongLines,
+	})
+	for text := range lr.Lines() {
+		select {
+		case p.in.lines <- Line{Source: p.spec.Name, Text: text, Time: at, Format: p.spec.Format}:
+		case <-ctx.Done():
+			return
+		}
+	}
+	p.add(lr.Stats())
+
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		p.in.report(ctx, fmt.Errorf("source %s: %w", p.spec.Name, err))
+	}
+}
+
+// add accumulates the line counts of one run.
+func (p *poller) add(s stream.ReaderStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Lines += s.Lines
+	p.stats.Truncated += s.Truncated
+	p.stats.Skipped += s.Skipped
+	p.stats.Repaired += s.Repaired
+}
+
+// Stats returns the line counts over all runs.
+func (p *poller) Stats() stream.ReaderStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
@@ -0,0 +1,440 @@
+// Package source opens named inputs (stdin, files, FIFOs, commands, polled
+// commands and system metrics) and fans their lines in to a single channel,
+// tagged with the source name.
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danqzq/rift/internal/clock"
+	"github.com/danqzq/rift/internal/format"
+	"github.com/danqzq/rift/internal/stream"
+	"github.com/danqzq/rift/internal/sys"
+)
+
+// Kind is the type of an input.
+type Kind string
+
+const (
+	Stdin   Kind = "stdin"
+	File    Kind = "file" // a regular file or a FIFO
+	Command Kind = "cmd"  // a shell command whose stdout is read
+	Poll    Kind = "poll" // a shell command run every Interval
+	Sys     Kind = "sys"  // system metrics collected every Interval
+)
+
+// DefaultSysInterval is how often a sys source collects by default.
+const DefaultSysInterval = time.Second
+
+// Spec describes one named input.
+type Spec struct {
+	Name   string
+	Kind   Kind
+	Target string // path or shell command
+
+	// Poll and sys options
+	Interval time.Duration
+	Timeout  time.Duration     // kill a run after this long (0 = Interval)
+	Format   format.FormatType // parser for the output ("" = detect per line)
+	PID      int               // process to track with sys
+}
+
+// Parse parses a source spec: "name=path", "name=cmd:command", "name=-" for
+// stdin, or a bare "name" for stdin under that name. A polled command is
+// "name=poll:5s:command", with optional settings after the interval:
+// "name=poll:5s,timeout=2s,format=json:command". System metrics are
+// "name=sys", "name=sys:5s" or "name=sys:1s,pid=1234".
+func Parse(s string) (Spec, error) {
+	name, target, found := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Spec{}, fmt.Errorf("invalid source %q, expected name=path or name=cmd:command", s)
+	}
+	target = strings.TrimSpace(target)
+	switch {
+	case !found || target == "-":
+		return Spec{Name: name, Kind: Stdin}, nil
+	case strings.HasPrefix(target, "cmd:"):
+		cmd := strings.TrimSpace(strings.TrimPrefix(target, "cmd:"))
+		if cmd == "" {
+			return Spec{}, fmt.Errorf("source %s: empty command", name)
+		}
+		return Spec{Name: name, Kind: Command, Target: cmd}, nil
+	case strings.HasPrefix(target, "poll:"):
+		spec, err := parsePoll(strings.TrimPrefix(target, "poll:"))
+		if err != nil {
+			return Spec{}, fmt.Errorf("source %s: %w", name, err)
+		}
+		spec.Name = name
+		return spec, nil
+	case target == "sys" || strings.HasPrefix(target, "sys:"):
+		spec, err := parseSys(strings.TrimPrefix(strings.TrimPrefix(target, "sys"), ":"))
+		if err != nil {
+			return Spec{}, fmt.Errorf("source %s: %w", name, err)
+		}
+		spec.Name = name
+		return spec, nil
+	case target == "":
+		return Spec{}, fmt.Errorf("source %s: empty path", name)
+	}
+	return Spec{Name: name, Kind: File, Target: target}, nil
+}
+
+// parsePoll parses "interval[,key=value...]:command".
+func parsePoll(s string) (Spec, error) {
+	opts, cmd, found := strings.Cut(s, ":")
+	cmd = strings.TrimSpace(cmd)
+	if !found || cmd == "" {
+		return Spec{}, fmt.Errorf("expected poll:INTERVAL:command")
+	}
+	spec := Spec{Kind: Poll, Target: cmd}
+	for i, opt := range strings.Split(opts, ",") {
+		opt = strings.TrimSpace(opt)
+		if i == 0 {
+			d, err := time.ParseDuration(opt)
+			if err != nil || d <= 0 {
+				return Spec{}, fmt.Errorf("invalid poll interval %q", opt)
+			}
+			spec.Interval = d
+			continue
+		}
+		key, val, _ := strings.Cut(opt, "=")
+		switch key {
+		case "timeout":
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				return Spec{}, fmt.Errorf("invalid poll timeout %q", val)
+			}
+			spec.Timeout = d
+		case "format":
+			f, err := format.ParseFormat(val)
+			if err != nil {
+				return Spec{}, err
+			}
+			spec.Format = f
+		default:
+			return Spec{}, fmt.Errorf("unknown poll option %q, expected timeout or format", key)
+		}
+	}
+	return spec, nil
+}
+
+// parseSys parses "[interval][,pid=N]".
+func parseSys(s string) (Spec, error) {
+	spec := Spec{Kind: Sys, Interval: DefaultSysInterval}
+	if s == "" {
+		return spec, nil
+	}
+	for i, opt := range strings.Split(s, ",") {
+		opt = strings.TrimSpace(opt)
+		key, val, found := strings.Cut(opt, "=")
+		switch {
+		case i == 0 && !found:
+			d, err := time.ParseDuration(opt)
+			if err != nil || d <= 0 {
+				return Spec{}, fmt.Errorf("invalid sys interval %q", opt)
+			}
+			spec.Interval = d
+		case key == "pid":
+			pid, err := strconv.Atoi(val)
+			if err != nil || pid <= 0 {
+				return Spec{}, fmt.Errorf("invalid pid %q", val)
+			}
+			spec.PID = pid
+		default:
+			return Spec{}, fmt.Errorf("unknown sys option %q, expected an interval or pid", opt)
+		}
+	}
+	return spec, nil
+}
+
+// ParseAll parses source specs, defaulting to stdin named "stdin" when there
+// are none. Names must be unique and at most one source may read stdin.
+func ParseAll(specs []string) ([]Spec, error) {
+	if len(specs) == 0 {
+		return []Spec{{Name: "stdin", Kind: Stdin}}, nil
+	}
+	var out []Spec
+	names := make(map[string]bool)
+	stdin := false
+	for _, s := range specs {
+		spec, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("duplicate source name %q", spec.Name)
+		}
+		names[spec.Name] = true
+		if spec.Kind == Stdin {
+			if stdin {
+				return nil, fmt.Errorf("only one source can read stdin")
+			}
+			stdin = true
+		}
+		out = append(out, spec)
+	}
+	return out, nil
+}
+
+// Line is an input line and the name of the source it came from.
+type Line struct {
+	Source string
+	Text   string
+	Time   time.Time         // when a polled command ran; zero for streamed lines
+	Format format.FormatType // parser to use ("" = detect)
+}
+
+// Parsers keeps a parser per source, so that state one stream's lines
+// depend on, such as a CSV header row, is not shared with another's.
+type Parsers struct {
+	base     format.Parser
+	bySource map[string]*format.Parser
+}
+
+// NewParsers returns Parsers that start each source's parser as a copy of
+// base.
+func NewParsers(base format.Parser) *Parsers {
+	return &Parsers{base: base, bySource: make(map[string]*format.Parser)}
+}
+
+// Parse parses the line with its source's parser, using the line's own
+// format if it has one, and tags its points with the source. Points without
+// a timestamp of their own get the poll time, or arrival for streamed lines.
+func (ps *Parsers) Parse(l Line, arrival time.Time) format.ParseResult {
+	p, ok := ps.bySource[l.Source]
+	if !ok {
+		clone := ps.base
+		p = &clone
+		ps.bySource[l.Source] = p
+	}
+	if l.Format != "" {
+		p.Format = l.Format
+	}
+	if !l.Time.IsZero() {
+		arrival = l.Time
+	}
+	result := p.ParseAt(l.Text, arrival)
+	for i := range result.Points {
+		result.Points[i].Source = l.Source
+	}
+	return result
+}
+
+// Input reads every source concurrently through its own LineReader, so a
+// slow or stalled source does not hold up the others.
+type Input struct {
+	lines  chan Line
+	errors chan error
+	stats  []func() stream.ReaderStats
+	config stream.ReaderConfig
+	clock  clock.Clock
+}
+
+// Open starts reading the sources. Lines arrive on Lines until every source
+// has ended or ctx is cancelled. Poll and sys sources are scheduled and
+// stamped by clk (nil uses the system clock).
+func Open(ctx context.Context, specs []Spec, config stream.ReaderConfig, clk clock.Clock) (*Input, error) {
+	in := &Input{
+		lines:  make(chan Line),
+		errors: make(chan error, len(specs)),
+		config: config,
+		clock:  clock.Or(clk),
+	}
+
+	var wg sync.WaitGroup
+	for _, spec := range specs {
+		switch spec.Kind {
+		case Poll:
+			p := &poller{spec: spec, config: config, in: in}
+			in.stats = append(in.stats, p.Stats)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.run(ctx)
+			}()
+			continue
+		case Sys:
+			s := &sysSource{spec: spec, in: in, collector: sys.NewCollector(spec.PID)}
+			in.stats = append(in.stats, s.Stats)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.run(ctx)
+			}()
+			continue
+		}
+
+		r, wait, err := open(ctx, spec)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", spec.Name, err)
+		}
+		lr := stream.NewLineReaderConfig(ctx, r, config)
+		in.stats = append(in.stats, lr.Stats)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer r.Close()
+			for text := range lr.Lines() {
+				select {
+				case in.lines <- Line{Source: spec.Name, Text: text}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			err := <-lr.Errors()
+			if err == nil {
+				err = wait()
+			}
+			if err != nil && ctx.Err() == nil {
+				in.report(ctx, fmt.Errorf("source %s: %w", spec.Name, err))
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(in.lines)
+	}()
+	return in, nil
+}
+
+// report sends a source error, unless ctx is cancelled first.
+func (in *Input) report(ctx context.Context, err error) {
+	select {
+	case in.errors <- err:
+	case <-ctx.Done():
+	}
+}
+
+// open returns a reader for the source and a function that reports how it
+// ended once the reader is exhausted.
+func open(ctx context.Context, spec Spec) (io.ReadCloser, func() error, error) {
+	noWait := func() error { return nil }
+	switch spec.Kind {
+	case Stdin:
+		return io.NopCloser(os.Stdin), noWait, nil
+
+	case Command:
+		cmd := exec.CommandContext(ctx, "sh", "-c", spec.Target)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, err
+		}
+		wait := func() error {
+			if err := cmd.Wait(); err != nil {
+				if msg := lastLine(stderr.String()); msg != "" {
+					return fmt.Errorf("%w: %s", err, msg)
+				}
+				return err
+			}
+			return nil
+		}
+		return out, wait, nil
+	}
+
+	info, err := os.Stat(spec.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Mode()&os.ModeNamedPipe != 0 {
+		return &fifo{path: spec.Target}, noWait, nil
+	}
+	f, err := os.Open(spec.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, noWait, nil
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// fifo reads a named pipe, reopening it whenever the writer closes it so
+// writers can come and go.
+type fifo struct {
+	path string
+	f    *os.File
+}
+
+func (p *fifo) Read(b []byte) (int, error) {
+	for {
+		if p.f == nil {
+			f, err := os.Open(p.path) // blocks until a writer opens the pipe
+			if err != nil {
+				return 0, err
+			}
+			p.f = f
+		}
+		n, err := p.f.Read(b)
+		if errors.Is(err, io.EOF) {
+			p.f.Close()
+			p.f = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (p *fifo) Close() error {
+	if p.f == nil {
+		return nil
+	}
+	return p.f.Close()
+}
+
+// Lines returns the merged lines of all sources. It is closed when every
+// source has ended.
+func (in *Input) Lines() <-chan Line {
+	return in.lines
+}
+
+// Errors returns errors from sources that failed, such as a command exiting
+// with a non-zero status.
+func (in *Input) Errors() <-chan error {
+	return in.errors
+}
+
+// Stats returns the line counts summed over all sources.
+func (in *Input) Stats() stream.ReaderStats {
+	var total stream.ReaderStats
+	for _, stats := range in.stats {
+		s := stats()
+		total.Lines += s.Lines
+		total.Dropped += s.Dropped
+		total.Sampled += s.Sampled
+		total.Truncated += s.Truncated
+		total.Skipped += s.Skipped
+		total.Repaired += s.Repaired
+	}
+	return total
+}
+
+// Policy returns the backpressure policy of the sources.
+func (in *Input) Policy() stream.Policy {
+	if in.config.Policy == "" {
+		return stream.Block
+	}
+	return in.config.Policy
+}
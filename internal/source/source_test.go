@@ -0,0 +1,266 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danqzq/rift/internal/clock"
+	"github.com/danqzq/rift/internal/format"
+	"github.com/danqzq/rift/internal/stream"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Spec
+		wantErr bool
+	}{
+		{"api=./api.log", Spec{Name: "api", Kind: File, Target: "./api.log"}, false},
+		{"db=cmd:psql -c 'select 1'", Spec{Name: "db", Kind: Command, Target: "psql -c 'select 1'"}, false},
+		{"in=-", Spec{Name: "in", Kind: Stdin}, false},
+		{"app", Spec{Name: "app", Kind: Stdin}, false},
+		{"conns=poll:5s:ss -s | grep TCP", Spec{Name: "conns", Kind: Poll, Target: "ss -s | grep TCP", Interval: 5 * time.Second}, false},
+		{"redis=poll:1s, timeout=3s, format=raw:redis-cli info", Spec{Name: "redis", Kind: Poll, Target: "redis-cli info",
+			Interval: time.Second, Timeout: 3 * time.Second, Format: format.FormatRaw}, false},
+		{"host=sys", Spec{Name: "host", Kind: Sys, Interval: time.Second}, false},
+		{"app=sys:2s,pid=1234", Spec{Name: "app", Kind: Sys, Interval: 2 * time.Second, PID: 1234}, false},
+		{"app=sys:pid=1234", Spec{Name: "app", Kind: Sys, Interval: time.Second, PID: 1234}, false},
+		{"app=sys:pid=x", Spec{}, true},
+		{"app=sys:fast", Spec{}, true},
+		{"x=poll:0s:date", Spec{}, true},
+		{"x=poll:1s", Spec{}, true},
+		{"x=poll:1s,retries=2:date", Spec{}, true},
+		{"x=poll:1s,format=xml:date", Spec{}, true},
+		{"=x", Spec{}, true},
+		{"db=cmd:", Spec{}, true},
+		{"api=", Spec{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	specs, err := ParseAll(nil)
+	if err != nil || len(specs) != 1 || specs[0].Kind != Stdin || specs[0].Name != "stdin" {
+		t.Errorf("ParseAll(nil) = %+v, %v, want stdin", specs, err)
+	}
+	for _, bad := range [][]string{
+		{"a=x", "a=y"},
+		{"a", "b=-"},
+	} {
+		if _, err := ParseAll(bad); err == nil {
+			t.Errorf("ParseAll(%q) should fail", bad)
+		}
+	}
+}
+
+func TestOpen_FansInTaggedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.log")
+	if err := os.WriteFile(path, []byte("latency=12\nlatency=15\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := ParseAll([]string{"api=" + path, "db=cmd:echo conns=3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := Open(context.Background(), specs, stream.ReaderConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for line := range in.Lines() {
+		got = append(got, line.Source+" "+line.Text)
+	}
+	sort.Strings(got)
+
+	want := []string{"api latency=12", "api latency=15", "db conns=3"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if n := in.Stats().Lines; n != 3 {
+		t.Errorf("Stats().Lines = %d, want 3", n)
+	}
+}
+
+func TestOpen_CommandFailure(t *testing.T) {
+	specs := []Spec{{Name: "db", Kind: Command, Target: "echo 1; echo 'connection refused' >&2; exit 2"}}
+	in, err := Open(context.Background(), specs, stream.ReaderConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range in.Lines() {
+	}
+	select {
+	case err := <-in.Errors():
+		if !strings.Contains(err.Error(), "source db") || !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("error = %v, want source name and stderr", err)
+		}
+	default:
+		t.Error("expected an error for the failed command")
+	}
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	specs := []Spec{{Name: "api", Kind: File, Target: filepath.Join(t.TempDir(), "missing.log")}}
+	if _, err := Open(context.Background(), specs, stream.ReaderConfig{}, nil); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+// advanceUntil moves clk forward by step until ready delivers.
+func advanceUntil[T any](t *testing.T, clk *clock.Fake, step time.Duration, ready <-chan T) T {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case v := <-ready:
+			return v
+		case <-time.After(time.Millisecond):
+			clk.Advance(step)
+		case <-deadline:
+			t.Fatal("source did not respond to the clock")
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	interval := 5 * time.Second
+	specs := []Spec{{Name: "du", Kind: Poll, Target: "echo size=42", Interval: interval, Timeout: time.Hour}}
+	in, err := Open(ctx, specs, stream.ReaderConfig{}, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Advance only while the source is idle, so no run is cut short; a
+	// poll skipped because the previous run was still going is fine
+	var times []time.Time
+	deadline := time.After(5 * time.Second)
+	for len(times) < 3 {
+		select {
+		case line := <-in.Lines():
+			if line.Source != "du" || line.Text != "size=42" {
+				t.Fatalf("line = %+v", line)
+			}
+			times = append(times, line.Time)
+		case <-in.Errors():
+		case <-time.After(10 * time.Millisecond):
+			clk.Advance(interval)
+		case <-deadline:
+			t.Fatalf("got %d polls, want 3", len(times))
+		}
+	}
+	if !times[0].Equal(start) {
+		t.Errorf("first poll at %v, want %v", times[0], start)
+	}
+	for i := 1; i < len(times); i++ {
+		if !times[i].After(times[i-1]) || times[i].Sub(start)%interval != 0 {
+			t.Errorf("poll times should advance by the interval, got %v", times)
+		}
+	}
+}
+
+func TestPoll_Timeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	specs := []Spec{{Name: "slow", Kind: Poll, Target: "exec sleep 5", Interval: time.Hour, Timeout: 2 * time.Second}}
+	in, err := Open(ctx, specs, stream.ReaderConfig{}, clk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := advanceUntil(t, clk, time.Second, in.Errors()); !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("error = %v, want a timeout", err)
+	}
+}
+
+func TestPoll_FailureKeepsOutput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	specs := []Spec{{Name: "matches", Kind: Poll, Target: "echo 0; exit 1", Interval: time.Hour, Format: format.FormatRaw}}
+	in, err := Open(ctx, specs, stream.ReaderConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line := <-in.Lines(); line.Text != "0" || line.Format != format.FormatRaw {
+		t.Errorf("line = %+v, want the output of the failed run", line)
+	}
+	if err := <-in.Errors(); !strings.Contains(err.Error(), "exit status 1") {
+		t.Errorf("error = %v, want the exit status", err)
+	}
+}
+
+func TestSys(t *testing.T) {
+	if _, err := os.Stat("/proc/stat"); err != nil {
+		t.Skip("no /proc on this system")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	specs := []Spec{{Name: "host", Kind: Sys, Interval: time.Hour, PID: os.Getpid()}}
+	in, err := Open(ctx, specs, stream.ReaderConfig{}, clock.NewFake(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := <-in.Lines()
+	if !line.Time.Equal(at) {
+		t.Errorf("collected at %v, want the clock's time %v", line.Time, at)
+	}
+	labels := make(map[string]bool)
+	for _, p := range NewParsers(format.Parser{}).Parse(line, time.Now()).Points {
+		if p.Source != "host" || !p.Timestamp.Equal(line.Time) {
+			t.Errorf("point %+v should come from host at the collection time", p)
+		}
+		labels[p.Label] = true
+	}
+	for _, want := range []string{"mem.used_pct", "load.1", "proc.rss_bytes"} {
+		if !labels[want] {
+			t.Errorf("missing %s in %s", want, line.Text)
+		}
+	}
+}
+
+func TestParsers_HeaderPerSource(t *testing.T) {
+	ps := NewParsers(format.Parser{})
+	lines := []Line{
+		{Source: "a", Text: "host,cpu"},
+		{Source: "b", Text: "host,mem"},
+		{Source: "a", Text: "web1,5"},
+		{Source: "b", Text: "web1,70"},
+		{Source: "c", Text: "web1,1"},
+	}
+	var got []string
+	for _, l := range lines {
+		for _, p := range ps.Parse(l, time.Now()).Points {
+			got = append(got, fmt.Sprintf("%s:%s=%v", p.Source, p.Label, p.Value))
+		}
+	}
+	want := []string{"a:web1.cpu=5", "b:web1.mem=70", "c:web1=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("points = %v, want %v", got, want)
+	}
+}
@@ -14,20 +14,46 @@ type DataPoint struct {
 	// Label is an optional identifier for categorical data (e.g., "cpu", "memory").
 	Label string
 
+	// Unit is the canonical unit of Value: "s", "B" or "%" ("" = none).
+	Unit string
+
 	// Raw stores the original input string for debugging purposes.
 	Raw string
+
+	// Source names the input the point was read from (e.g., "api", "db").
+	Source string
+
+	// Tags holds text fields captured along with the value (e.g., "status").
+	Tags map[string]string
+
+	// Anomaly is set when anomaly detection flagged the value as an outlier.
+	Anomaly bool
 }
 
+// NewDataPoint creates a data point stamped with the system clock's current
+// time. Use NewDataPointAt to stamp it with a clock.Clock instead.
 func NewDataPoint(value float64) DataPoint {
+	return NewDataPointAt(value, time.Now())
+}
+
+// NewDataPointAt creates a data point with the given timestamp.
+func NewDataPointAt(value float64, t time.Time) DataPoint {
 	return DataPoint{
-		Timestamp: time.Now(),
+		Timestamp: t,
 		Value:     value,
 	}
 }
 
+// NewLabeledDataPoint creates a labeled data point stamped with the system
+// clock's current time.
 func NewLabeledDataPoint(label string, value float64) DataPoint {
+	return NewLabeledDataPointAt(label, value, time.Now())
+}
+
+// NewLabeledDataPointAt creates a labeled data point with the given timestamp.
+func NewLabeledDataPointAt(label string, value float64, t time.Time) DataPoint {
 	return DataPoint{
-		Timestamp: time.Now(),
+		Timestamp: t,
 		Value:     value,
 		Label:     label,
 	}
@@ -0,0 +1,85 @@
+package stream
+
+// dequeEntry pairs a point's sequence number with its value.
+type dequeEntry struct {
+	seq   uint64
+	value float64
+}
+
+// deque is a growable double-ended queue backed by a ring buffer.
+type deque struct {
+	buf  []dequeEntry
+	head int
+	size int
+}
+
+func (d *deque) len() int {
+	return d.size
+}
+
+func (d *deque) front() dequeEntry {
+	return d.buf[d.head]
+}
+
+func (d *deque) back() dequeEntry {
+	return d.buf[(d.head+d.size-1)%len(d.buf)]
+}
+
+func (d *deque) pushBack(e dequeEntry) {
+	if d.size == len(d.buf) {
+		d.grow()
+	}
+	d.buf[(d.head+d.size)%len(d.buf)] = e
+	d.size++
+}
+
+func (d *deque) popBack() {
+	d.size--
+}
+
+func (d *deque) popFront() {
+	d.head = (d.head + 1) % len(d.buf)
+	d.size--
+}
+
+func (d *deque) reset() {
+	d.head = 0
+	d.size = 0
+}
+
+// grow doubles the capacity, unwrapping the entries to the start of the buffer.
+func (d *deque) grow() {
+	capacity := len(d.buf) * 2
+	if capacity == 0 {
+		capacity = 16
+	}
+
+	buf := make([]dequeEntry, capacity)
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:d.head])
+	d.buf = buf
+	d.head = 0
+}
+
+// monotonicQueue tracks the minimum (or maximum) of a sliding window in
+// amortised O(1) per operation. Entries are kept in window order with
+// values that only get worse towards the back, so the front is the extreme.
+type monotonicQueue struct {
+	deque
+	better func(a, b float64) bool // reports whether a should displace b
+}
+
+// push adds a value, dropping entries from the back that can never be the extreme again.
+func (q *monotonicQueue) push(seq uint64, value float64) {
+	for q.len() > 0 && !q.better(q.back().value, value) {
+		q.popBack()
+	}
+	q.pushBack(dequeEntry{seq: seq, value: value})
+}
+
+// evict removes the front entry if it belongs to the point with sequence seq.
+func (q *monotonicQueue) evict(seq uint64) {
+	if q.len() > 0 && q.front().seq == seq {
+		q.popFront()
+	}
+}
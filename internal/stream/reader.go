@@ -2,50 +2,229 @@ package stream
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
+// Policy decides what a LineReader does when its buffer is full because the
+// consumer has fallen behind.
+type Policy string
+
+const (
+	// Block stops reading until the consumer catches up, which can stall
+	// the program writing to the input.
+	Block Policy = "block"
+	// DropOldest discards the oldest buffered line to make room.
+	DropOldest Policy = "drop-oldest"
+	// DropNewest discards the incoming line.
+	DropNewest Policy = "drop-newest"
+	// Sample keeps a uniform random sample of the lines that arrived since
+	// the buffer was last empty, in arrival order.
+	Sample Policy = "sample"
+)
+
+// ParsePolicy parses a backpressure policy name.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case Block, DropOldest, DropNewest, Sample:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown backpressure policy %q, expected block, drop-oldest, drop-newest or sample", s)
+}
+
+// LongLines decides what a LineReader does with lines over its maximum
+// length.
+type LongLines string
+
+const (
+	// Truncate keeps the first MaxLine bytes of the line.
+	Truncate LongLines = "truncate"
+	// Skip discards the line.
+	Skip LongLines = "skip"
+)
+
+// ParseLongLines parses a long-line policy name.
+func ParseLongLines(s string) (LongLines, error) {
+	switch l := LongLines(s); l {
+	case Truncate, Skip:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown long-line policy %q, expected truncate or skip", s)
+}
+
+const (
+	// DefaultBuffer is the number of lines a LineReader buffers by default.
+	DefaultBuffer = 100
+	// DefaultMaxLine is the default maximum line length in bytes.
+	DefaultMaxLine = 1 << 20
+)
+
+// ReaderConfig configures a LineReader.
+type ReaderConfig struct {
+	Buffer    int       // lines buffered ahead of the consumer (0 = DefaultBuffer)
+	Policy    Policy    // what to do when the buffer is full ("" = Block)
+	MaxLine   int       // maximum line length in bytes (0 = DefaultMaxLine)
+	LongLines LongLines // what to do with longer lines ("" = Truncate)
+}
+
+// ReaderStats counts the lines a LineReader has read, altered and discarded.
+type ReaderStats struct {
+	Lines     uint64 // lines read from the input
+	Dropped   uint64 // lines discarded by DropOldest or DropNewest
+	Sampled   uint64 // lines discarded by Sample
+	Truncated uint64 // lines cut to MaxLine
+	Skipped   uint64 // lines over MaxLine discarded by Skip
+	Repaired  uint64 // lines with invalid UTF-8 or NUL bytes replaced
+}
+
+// Discarded returns the number of lines that never reached the consumer.
+func (s ReaderStats) Discarded() uint64 {
+	return s.Dropped + s.Sampled + s.Skipped
+}
+
+// Intact reports whether every line reached the consumer unchanged.
+func (s ReaderStats) Intact() bool {
+	return s.Discarded() == 0 && s.Truncated == 0 && s.Repaired == 0
+}
+
+func (s ReaderStats) String() string {
+	out := fmt.Sprintf("%d lines", s.Lines)
+	for _, c := range []struct {
+		n    uint64
+		what string
+	}{
+		{s.Dropped, "dropped"},
+		{s.Sampled, "sampled out"},
+		{s.Skipped, "too long, skipped"},
+		{s.Truncated, "truncated"},
+		{s.Repaired, "repaired"},
+	} {
+		if c.n > 0 {
+			out += fmt.Sprintf(", %d %s", c.n, c.what)
+		}
+	}
+	if n := s.Discarded(); n > 0 && s.Lines > 0 {
+		out += fmt.Sprintf(" (%.1f%% lost)", 100*float64(n)/float64(s.Lines))
+	}
+	return out
+}
+
 // LineReader provides non-blocking line-by-line reading from an io.Reader.
 type LineReader struct {
 	lines  chan string
 	errors chan error
 	done   chan struct{}
 	cancel context.CancelFunc
+
+	config ReaderConfig
+
+	// Buffer used by the non-blocking policies: the scanner appends under
+	// mu and the pump goroutine moves lines to the lines channel.
+	mu     sync.Mutex
+	buf    []string
+	seen   uint64 // lines offered since buf was last empty, for Sample
+	eof    bool
+	notify chan struct{}
+
+	read, dropped, sampled       atomic.Uint64
+	truncated, skipped, repaired atomic.Uint64
 }
 
+// NewLineReader creates a reader that blocks when DefaultBuffer lines are
+// waiting for the consumer.
 func NewLineReader(ctx context.Context, r io.Reader) *LineReader {
+	return NewLineReaderConfig(ctx, r, ReaderConfig{})
+}
+
+// NewLineReaderConfig creates a reader with the given buffer and backpressure
+// policy.
+func NewLineReaderConfig(ctx context.Context, r io.Reader, config ReaderConfig) *LineReader {
 	ctx, cancel := context.WithCancel(ctx)
+	if config.Buffer <= 0 {
+		config.Buffer = DefaultBuffer
+	}
+	if config.Policy == "" {
+		config.Policy = Block
+	}
+	if config.MaxLine <= 0 {
+		config.MaxLine = DefaultMaxLine
+	}
+	if config.LongLines == "" {
+		config.LongLines = Truncate
+	}
 
 	lr := &LineReader{
-		lines:  make(chan string, 100), // buffer to handle bursts
 		errors: make(chan error, 1),
 		done:   make(chan struct{}),
 		cancel: cancel,
+		config: config,
 	}
 
-	go lr.readLoop(ctx, r)
+	if config.Policy == Block {
+		lr.lines = make(chan string, config.Buffer) // buffer to handle bursts
+		go func() {
+			defer close(lr.done)
+			lr.readLoop(ctx, r)
+		}()
+		return lr
+	}
+
+	// The scanner never waits on the consumer; the pump does
+	lr.lines = make(chan string)
+	lr.buf = make([]string, 0, config.Buffer)
+	lr.notify = make(chan struct{}, 1)
+	pumped := make(chan struct{})
+	go lr.pump(ctx, pumped)
+	go func() {
+		defer close(lr.done)
+		lr.readLoop(ctx, r)
+		<-pumped
+	}()
 	return lr
 }
 
 // readLoop continuously reads lines and sends them to the channel.
 func (lr *LineReader) readLoop(ctx context.Context, r io.Reader) {
-	defer close(lr.done)
-	defer close(lr.lines)
 	defer close(lr.errors)
+	if lr.config.Policy == Block {
+		defer close(lr.lines)
+	} else {
+		defer lr.finish()
+	}
 
-	scanner := bufio.NewScanner(r)
+	br := bufio.NewReaderSize(r, min(lr.config.MaxLine, 64*1024))
+	var err error
 
-	for scanner.Scan() {
+	for err == nil {
+		var line string
+		var ok bool
+		if line, ok, err = lr.readLine(br); !ok {
+			continue
+		}
+		if lr.config.Policy != Block {
+			lr.offer(line)
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
 		select {
 		case <-ctx.Done():
 			return
-		case lr.lines <- scanner.Text():
+		case lr.lines <- line:
 			// line sent successfully
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	if !errors.Is(err, io.EOF) {
 		select {
 		case lr.errors <- err:
 		default:
@@ -54,6 +233,142 @@ func (lr *LineReader) readLoop(ctx context.Context, r io.Reader) {
 	}
 }
 
+// readLine reads the next line, without its LF or CRLF ending. Lines over
+// MaxLine are truncated or skipped without buffering the excess, and invalid
+// UTF-8 and NUL bytes are replaced with U+FFFD so parsers only see text. It
+// returns ok=false for a skipped line or at the end of input, and the read
+// error, if any, alongside the last line.
+func (lr *LineReader) readLine(br *bufio.Reader) (line string, ok bool, err error) {
+	// MaxLine limits the content, so keep room for a CRLF ending
+	limit := lr.config.MaxLine + len("\r\n")
+	var buf []byte
+	over := false
+	for {
+		var chunk []byte
+		chunk, err = br.ReadSlice('\n')
+		if n := limit - len(buf); len(chunk) > n {
+			over = true
+			chunk = chunk[:max(n, 0)]
+		}
+		buf = append(buf, chunk...)
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+	if len(buf) == 0 && err != nil {
+		return "", false, err
+	}
+	lr.read.Add(1)
+
+	if !over {
+		buf = bytes.TrimSuffix(buf, []byte("\n"))
+		buf = bytes.TrimSuffix(buf, []byte("\r"))
+	}
+	long := len(buf) > lr.config.MaxLine
+	if long {
+		buf = buf[:lr.config.MaxLine]
+		if lr.config.LongLines == Skip {
+			lr.skipped.Add(1)
+			return "", false, err
+		}
+		lr.truncated.Add(1)
+		buf = trimPartialRune(buf)
+	}
+
+	if !utf8.Valid(buf) || bytes.IndexByte(buf, 0) >= 0 {
+		lr.repaired.Add(1)
+		line = strings.ToValidUTF8(string(buf), "\uFFFD")
+		return strings.ReplaceAll(line, "\x00", "\uFFFD"), true, err
+	}
+	return string(buf), true, err
+}
+
+// trimPartialRune removes a UTF-8 sequence cut short at the end of b.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// offer buffers a line without waiting, applying the policy if full.
+func (lr *LineReader) offer(line string) {
+	lr.mu.Lock()
+	lr.seen++
+	switch {
+	case len(lr.buf) < lr.config.Buffer:
+		lr.buf = append(lr.buf, line)
+	case lr.config.Policy == DropNewest:
+		lr.dropped.Add(1)
+	case lr.config.Policy == DropOldest:
+		lr.buf = append(lr.buf[1:], line)
+		lr.dropped.Add(1)
+	case lr.config.Policy == Sample:
+		// Reservoir sampling: the new line stays with probability
+		// Buffer/seen, replacing a random buffered line. Removing that line
+		// and appending keeps the buffer in arrival order.
+		lr.sampled.Add(1)
+		if j := rand.Uint64N(lr.seen); j < uint64(len(lr.buf)) {
+			copy(lr.buf[j:], lr.buf[j+1:])
+			lr.buf[len(lr.buf)-1] = line
+		}
+	}
+	lr.mu.Unlock()
+	lr.wake()
+}
+
+// finish marks the input as ended so the pump closes lines once drained.
+func (lr *LineReader) finish() {
+	lr.mu.Lock()
+	lr.eof = true
+	lr.mu.Unlock()
+	lr.wake()
+}
+
+func (lr *LineReader) wake() {
+	select {
+	case lr.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends buffered lines to the consumer, waiting on it as long as needed.
+func (lr *LineReader) pump(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	defer close(lr.lines)
+	for {
+		lr.mu.Lock()
+		if len(lr.buf) == 0 {
+			lr.seen = 0
+			eof := lr.eof
+			lr.mu.Unlock()
+			if eof {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-lr.notify:
+			}
+			continue
+		}
+		line := lr.buf[0]
+		lr.buf = lr.buf[1:]
+		lr.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case lr.lines <- line:
+		}
+	}
+}
+
 func (lr *LineReader) Lines() <-chan string {
 	return lr.lines
 }
@@ -66,6 +381,23 @@ func (lr *LineReader) Done() <-chan struct{} {
 	return lr.done
 }
 
+// Stats returns the number of lines read and discarded so far.
+func (lr *LineReader) Stats() ReaderStats {
+	return ReaderStats{
+		Lines:     lr.read.Load(),
+		Dropped:   lr.dropped.Load(),
+		Sampled:   lr.sampled.Load(),
+		Truncated: lr.truncated.Load(),
+		Skipped:   lr.skipped.Load(),
+		Repaired:  lr.repaired.Load(),
+	}
+}
+
+// Policy returns the reader's backpressure policy.
+func (lr *LineReader) Policy() Policy {
+	return lr.config.Policy
+}
+
 func (lr *LineReader) Stop() {
 	lr.cancel()
 	<-lr.done
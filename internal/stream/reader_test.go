@@ -0,0 +1,209 @@
+package stream
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// numberedInput returns n lines "1" to "n".
+func numberedInput(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		b.WriteString(strconv.Itoa(i))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// drain waits for the reader to read all n lines, so the buffer has
+// overflowed, then collects what reaches the consumer.
+func drain(t *testing.T, lr *LineReader, n uint64) []int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for lr.Stats().Lines < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("reader stalled at %d of %d lines", lr.Stats().Lines, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var got []int
+	for line := range lr.Lines() {
+		v, _ := strconv.Atoi(line)
+		got = append(got, v)
+	}
+	return got
+}
+
+func TestLineReader_Policies(t *testing.T) {
+	const n, buffer = 5000, 50
+
+	tests := []struct {
+		policy Policy
+		check  func(t *testing.T, got []int)
+	}{
+		{DropNewest, func(t *testing.T, got []int) {
+			if got[0] != 1 {
+				t.Errorf("drop-newest should keep the first line, got %d", got[0])
+			}
+		}},
+		{DropOldest, func(t *testing.T, got []int) {
+			if got[len(got)-1] != n {
+				t.Errorf("drop-oldest should keep the last line, got %d", got[len(got)-1])
+			}
+		}},
+		{Sample, func(t *testing.T, got []int) {
+			if got[0] > n/2 || got[len(got)-1] <= n/2 {
+				t.Errorf("sample should span the input, got %v", got)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			lr := NewLineReaderConfig(context.Background(), strings.NewReader(numberedInput(n)),
+				ReaderConfig{Buffer: buffer, Policy: tt.policy})
+			got := drain(t, lr, n)
+
+			// One line may be in flight to the consumer besides the buffer
+			if len(got) == 0 || len(got) > buffer+1 {
+				t.Fatalf("got %d lines, want 1 to %d", len(got), buffer+1)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i] <= got[i-1] {
+					t.Fatalf("lines out of order: %v", got)
+				}
+			}
+			stats := lr.Stats()
+			if stats.Lines != n || uint64(len(got))+stats.Discarded() != n {
+				t.Errorf("stats %+v do not account for %d delivered of %d", stats, len(got), n)
+			}
+			if (tt.policy == Sample) != (stats.Sampled > 0) {
+				t.Errorf("stats %+v: discards counted under the wrong policy", stats)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestLineReader_BlockDeliversEverything(t *testing.T) {
+	const n = 500
+	lr := NewLineReaderConfig(context.Background(), strings.NewReader(numberedInput(n)),
+		ReaderConfig{Buffer: 5})
+	var got int
+	for range lr.Lines() {
+		got++
+	}
+	if got != n || lr.Stats().Discarded() != 0 {
+		t.Errorf("got %d lines with stats %+v, want all %d", got, lr.Stats(), n)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, s := range []string{"block", "drop-oldest", "drop-newest", "sample"} {
+		if p, err := ParsePolicy(s); err != nil || string(p) != s {
+			t.Errorf("ParsePolicy(%q) = %q, %v", s, p, err)
+		}
+	}
+	if _, err := ParsePolicy("drop"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestLineReader_LineHandling(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	tests := []struct {
+		name      string
+		input     string
+		longLines LongLines
+		want      []string
+		wantStats ReaderStats
+	}{
+		{
+			name:      "crlf",
+			input:     "1\r\n2\r\n3",
+			want:      []string{"1", "2", "3"},
+			wantStats: ReaderStats{Lines: 3},
+		},
+		{
+			name:      "truncate",
+			input:     "1\n" + long + "\n2\n",
+			want:      []string{"1", long[:16], "2"},
+			wantStats: ReaderStats{Lines: 3, Truncated: 1},
+		},
+		{
+			name:      "skip",
+			input:     "1\n" + long + "\n2\n",
+			longLines: Skip,
+			want:      []string{"1", "2"},
+			wantStats: ReaderStats{Lines: 3, Skipped: 1},
+		},
+		{
+			name:      "exactly max line",
+			input:     long[:16] + "\n" + long[:16] + "\r\n" + long[:16],
+			longLines: Skip,
+			want:      []string{long[:16], long[:16], long[:16]},
+			wantStats: ReaderStats{Lines: 3},
+		},
+		{
+			name:      "one over max line",
+			input:     long[:17] + "\n" + long[:17] + "\r\n",
+			want:      []string{long[:16], long[:16]},
+			wantStats: ReaderStats{Lines: 2, Truncated: 2},
+		},
+		{
+			name:      "truncate keeps whole runes",
+			input:     strings.Repeat("é", 10) + "\n",
+			want:      []string{strings.Repeat("é", 8)},
+			wantStats: ReaderStats{Lines: 1, Truncated: 1},
+		},
+		{
+			name:      "truncate unterminated last line",
+			input:     long,
+			want:      []string{long[:16]},
+			wantStats: ReaderStats{Lines: 1, Truncated: 1},
+		},
+		{
+			name:      "invalid utf8 and nul",
+			input:     "cpu=\xff1\nmem\x00=2\n",
+			want:      []string{"cpu=�1", "mem�=2"},
+			wantStats: ReaderStats{Lines: 2, Repaired: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := NewLineReaderConfig(context.Background(), strings.NewReader(tt.input),
+				ReaderConfig{MaxLine: 16, LongLines: tt.longLines})
+			var got []string
+			for line := range lr.Lines() {
+				got = append(got, line)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if stats := lr.Stats(); stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if err := <-lr.Errors(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLineReader_LongerThanScannerLimit(t *testing.T) {
+	// bufio.Scanner gives up at 64KB; a big JSON line must get through
+	line := `{"latency":` + strings.Repeat(" ", 200*1024) + `42}`
+	lr := NewLineReader(context.Background(), strings.NewReader(line+"\n7\n"))
+	var got []string
+	for l := range lr.Lines() {
+		got = append(got, l)
+	}
+	if len(got) != 2 || got[0] != line || got[1] != "7" {
+		t.Errorf("got %d lines, want the long line intact followed by 7", len(got))
+	}
+}
@@ -0,0 +1,205 @@
+package stream
+
+import (
+	"math"
+	"time"
+)
+
+// Bucket summarizes the points that fell into one time interval.
+type Bucket struct {
+	Start time.Time
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+	Last  float64 // value of the newest point in the bucket
+
+	Anomalies int // points flagged as anomalies
+
+	lastAt time.Time
+}
+
+// Mean returns the average value in the bucket, or 0 if it is empty.
+func (b Bucket) Mean() float64 {
+	if b.Count == 0 {
+		return 0
+	}
+	return b.Sum / float64(b.Count)
+}
+
+// add folds a point into the bucket.
+func (b *Bucket) add(p DataPoint) {
+	if b.Count == 0 {
+		b.Min, b.Max = p.Value, p.Value
+	}
+	b.Count++
+	b.Sum += p.Value
+	b.Min = math.Min(b.Min, p.Value)
+	b.Max = math.Max(b.Max, p.Value)
+	if p.Anomaly {
+		b.Anomalies++
+	}
+	if !p.Timestamp.Before(b.lastAt) {
+		b.Last = p.Value
+		b.lastAt = p.Timestamp
+	}
+}
+
+// merge folds another bucket into this one.
+func (b *Bucket) merge(o Bucket) {
+	if o.Count == 0 {
+		return
+	}
+	if b.Count == 0 {
+		b.Min, b.Max = o.Min, o.Max
+	}
+	b.Count += o.Count
+	b.Sum += o.Sum
+	b.Min = math.Min(b.Min, o.Min)
+	b.Max = math.Max(b.Max, o.Max)
+	b.Anomalies += o.Anomalies
+	if !o.lastAt.Before(b.lastAt) {
+		b.Last = o.Last
+		b.lastAt = o.lastAt
+	}
+}
+
+// RollupTier configures one resolution of a rollup store.
+type RollupTier struct {
+	Resolution time.Duration // bucket size
+	Retention  time.Duration // how far back buckets are kept
+}
+
+// RollupTiersFor returns tiers of 1s, 10s and 1m buckets, capped at history.
+// Resolutions that would keep fewer than two buckets are left out.
+func RollupTiersFor(history time.Duration) []RollupTier {
+	defaults := []RollupTier{
+		{Resolution: time.Second, Retention: 15 * time.Minute},
+		{Resolution: 10 * time.Second, Retention: 3 * time.Hour},
+		{Resolution: time.Minute, Retention: history},
+	}
+
+	var tiers []RollupTier
+	for _, t := range defaults {
+		t.Retention = min(t.Retention, history)
+		if t.Retention >= 2*t.Resolution {
+			tiers = append(tiers, t)
+		}
+	}
+	return tiers
+}
+
+// Rollup keeps downsampled buckets at several resolutions so long time
+// ranges can be charted without keeping every raw point.
+type Rollup struct {
+	tiers  []*rollupTier
+	latest time.Time
+}
+
+// rollupTier is a ring of buckets indexed by bucket number.
+type rollupTier struct {
+	RollupTier
+	buckets []Bucket
+}
+
+// NewRollup creates a rollup store with the given tiers, finest first.
+func NewRollup(tiers []RollupTier) *Rollup {
+	r := &Rollup{}
+	for _, t := range tiers {
+		if t.Resolution <= 0 || t.Retention < t.Resolution {
+			continue
+		}
+		n := int(t.Retention / t.Resolution)
+		r.tiers = append(r.tiers, &rollupTier{RollupTier: t, buckets: make([]Bucket, n)})
+	}
+	return r
+}
+
+// Add records a point in every tier.
+func (r *Rollup) Add(p DataPoint) {
+	if p.Timestamp.After(r.latest) {
+		r.latest = p.Timestamp
+	}
+
+	for _, t := range r.tiers {
+		start := p.Timestamp.Truncate(t.Resolution)
+		slot := &t.buckets[t.slot(start)]
+
+		switch {
+		case slot.Count == 0 || slot.Start.Before(start):
+			// Slot is empty or holds an expired bucket
+			*slot = Bucket{Start: start}
+		case slot.Start.After(start):
+			continue // point is older than the tier's retention
+		}
+		slot.add(p)
+	}
+}
+
+// Clear removes all buckets.
+func (r *Rollup) Clear() {
+	for _, t := range r.tiers {
+		clear(t.buckets)
+	}
+	r.latest = time.Time{}
+}
+
+// Range merges buckets between from and to (inclusive) into columns buckets
+// of equal width, using the finest tier that still covers from. Columns
+// without data have Count 0.
+func (r *Rollup) Range(from, to time.Time, columns int) []Bucket {
+	result := newColumns(from, to, columns)
+	if len(r.tiers) == 0 || len(result) == 0 {
+		return result
+	}
+
+	tier := r.tiers[len(r.tiers)-1]
+	for _, t := range r.tiers {
+		if !r.latest.Add(-t.Retention).After(from) {
+			tier = t
+			break
+		}
+	}
+
+	width := to.Sub(from) / time.Duration(columns)
+	for _, b := range tier.buckets {
+		if b.Count == 0 || b.Start.Before(from.Truncate(tier.Resolution)) || b.Start.After(to) {
+			continue
+		}
+		idx := columnIndex(b.Start, from, width, columns)
+		result[idx].merge(b)
+	}
+	return result
+}
+
+// slot returns the ring index for the bucket starting at start.
+func (t *rollupTier) slot(start time.Time) int {
+	n := start.UnixNano() / int64(t.Resolution)
+	idx := int(n % int64(len(t.buckets)))
+	if idx < 0 {
+		idx += len(t.buckets)
+	}
+	return idx
+}
+
+// newColumns creates empty buckets splitting the range from-to into columns.
+func newColumns(from, to time.Time, columns int) []Bucket {
+	if columns <= 0 || !to.After(from) {
+		return nil
+	}
+	width := to.Sub(from) / time.Duration(columns)
+	result := make([]Bucket, columns)
+	for i := range result {
+		result[i].Start = from.Add(time.Duration(i) * width)
+	}
+	return result
+}
+
+// columnIndex maps t to a column in [0, columns), clamping out-of-range times.
+func columnIndex(t, from time.Time, width time.Duration, columns int) int {
+	if width <= 0 {
+		return columns - 1
+	}
+	idx := int(t.Sub(from) / width)
+	return max(0, min(idx, columns-1))
+}
@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func pointAt(t time.Time, v float64) DataPoint {
+	p := NewDataPoint(v)
+	p.Timestamp = t
+	return p
+}
+
+func TestRollup_Range(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRollup([]RollupTier{
+		{Resolution: time.Second, Retention: time.Minute},
+		{Resolution: time.Minute, Retention: time.Hour},
+	})
+
+	// One point per second for 30 minutes, value = minute number
+	for s := 0; s < 30*60; s++ {
+		r.Add(pointAt(base.Add(time.Duration(s)*time.Second), float64(s/60)))
+	}
+	end := base.Add(30*time.Minute - time.Second)
+
+	// Last 10 seconds come from the 1s tier
+	recent := r.Range(end.Add(-9*time.Second), end, 10)
+	for i, b := range recent {
+		if b.Count != 1 || b.Last != 29 {
+			t.Errorf("recent column %d: count %d last %v, want 1/29", i, b.Count, b.Last)
+		}
+	}
+
+	// The whole half hour in 3 columns needs the 1m tier
+	all := r.Range(base, end, 3)
+	for i, b := range all {
+		if b.Count != 600 {
+			t.Errorf("column %d: count %d, want 600", i, b.Count)
+		}
+		if want := float64(i * 10); b.Min != want || b.Max != want+9 {
+			t.Errorf("column %d: min/max %v/%v, want %v/%v", i, b.Min, b.Max, want, want+9)
+		}
+	}
+}
+
+func TestWindow_RangeFallsBackToRollup(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWindow(WindowConfig{MaxSize: 10, Rollup: RollupTiersFor(time.Hour)})
+	for s := 0; s < 600; s++ {
+		w.Add(pointAt(base.Add(time.Duration(s)*time.Second), 1))
+	}
+	end := base.Add(599 * time.Second)
+
+	// Raw window only holds 10 points, but history is kept in rollups
+	buckets := w.Range(base, end, 10)
+	total := 0
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total != 600 {
+		t.Errorf("rollup range covered %d points, want 600", total)
+	}
+
+	// A range the raw window covers is served exactly from raw points
+	raw := w.Range(end.Add(-4*time.Second), end, 5)
+	for i, b := range raw {
+		if b.Count != 1 {
+			t.Errorf("raw column %d: count %d, want 1", i, b.Count)
+		}
+	}
+}
+
+func TestRollupTiersFor(t *testing.T) {
+	tiers := RollupTiersFor(6 * time.Hour)
+	if len(tiers) != 3 {
+		t.Fatalf("got %d tiers, want 3", len(tiers))
+	}
+	if tiers[2].Resolution != time.Minute || tiers[2].Retention != 6*time.Hour {
+		t.Errorf("coarsest tier = %+v, want 1m for 6h", tiers[2])
+	}
+
+	if short := RollupTiersFor(10 * time.Second); len(short) != 1 {
+		t.Errorf("10s history should only keep the 1s tier, got %+v", short)
+	}
+}
+
+func TestBucket_CountsAnomalies(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewFixedWindow(10)
+	for i := 0; i < 4; i++ {
+		p := pointAt(start.Add(time.Duration(i)*time.Second), float64(i))
+		p.Anomaly = i%2 == 1
+		w.Add(p)
+	}
+
+	buckets := w.Range(start, start.Add(4*time.Second), 2)
+	if len(buckets) != 2 || buckets[0].Anomalies != 1 || buckets[1].Anomalies != 1 {
+		t.Errorf("got %+v, want one anomaly per bucket", buckets)
+	}
+}
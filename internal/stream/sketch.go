@@ -0,0 +1,186 @@
+package stream
+
+import "math"
+
+// Sketch is a DDSketch: a quantile sketch with a bounded relative error.
+// Unlike most sketches it supports removing values, so it can follow a
+// sliding window, and two sketches with the same accuracy can be merged.
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+
+	positive sketchStore
+	negative sketchStore // keyed by the magnitude of negative values
+	zero     uint64      // values too close to zero to index
+	count    uint64
+}
+
+// minIndexable is the smallest magnitude given its own bucket.
+const minIndexable = 1e-9
+
+// NewSketch creates a sketch whose quantiles are within relativeAccuracy
+// (e.g. 0.01 for 1%) of the true value.
+func NewSketch(relativeAccuracy float64) *Sketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = 0.01
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+	}
+}
+
+// Add records a value. NaN and infinite values are ignored.
+func (s *Sketch) Add(v float64) {
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		return
+	case v > minIndexable:
+		s.positive.add(s.key(v), 1)
+	case v < -minIndexable:
+		s.negative.add(s.key(-v), 1)
+	default:
+		s.zero++
+	}
+	s.count++
+}
+
+// Remove forgets a value previously passed to Add.
+func (s *Sketch) Remove(v float64) {
+	switch {
+	case math.IsNaN(v) || math.IsInf(v, 0):
+		return
+	case v > minIndexable:
+		if !s.positive.remove(s.key(v)) {
+			return
+		}
+	case v < -minIndexable:
+		if !s.negative.remove(s.key(-v)) {
+			return
+		}
+	default:
+		if s.zero == 0 {
+			return
+		}
+		s.zero--
+	}
+	s.count--
+}
+
+// Merge adds all values recorded in other. Both sketches must have been
+// created with the same accuracy.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, c := range other.positive.counts {
+		if c > 0 {
+			s.positive.add(other.positive.offset+i, c)
+		}
+	}
+	for i, c := range other.negative.counts {
+		if c > 0 {
+			s.negative.add(other.negative.offset+i, c)
+		}
+	}
+	s.zero += other.zero
+	s.count += other.count
+}
+
+// Count returns the number of values in the sketch.
+func (s *Sketch) Count() int {
+	return int(s.count)
+}
+
+// Clear removes all values.
+func (s *Sketch) Clear() {
+	s.positive = sketchStore{}
+	s.negative = sketchStore{}
+	s.zero = 0
+	s.count = 0
+}
+
+// Quantile returns an estimate of the q-th quantile (0-1), or 0 if the
+// sketch is empty.
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(q, 1))
+	rank := uint64(q * float64(s.count-1))
+
+	// Negative values first, largest magnitude (smallest value) first
+	if rank < s.negative.total {
+		key := s.negative.keyAtRank(s.negative.total - 1 - rank)
+		return -s.value(key)
+	}
+	rank -= s.negative.total
+
+	if rank < s.zero {
+		return 0
+	}
+	rank -= s.zero
+
+	return s.value(s.positive.keyAtRank(rank))
+}
+
+// key returns the bucket index for a positive value.
+func (s *Sketch) key(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the representative value of a bucket, which is within the
+// relative accuracy of every value in it.
+func (s *Sketch) value(key int) float64 {
+	return 2 * math.Pow(s.gamma, float64(key)) / (s.gamma + 1)
+}
+
+// sketchStore holds dense bucket counts starting at key offset.
+type sketchStore struct {
+	counts []uint64
+	offset int
+	total  uint64
+}
+
+func (st *sketchStore) add(key int, n uint64) {
+	if len(st.counts) == 0 {
+		st.counts = make([]uint64, 1, 64)
+		st.offset = key
+	}
+
+	if key < st.offset {
+		// Extend to the left
+		grow := st.offset - key
+		counts := make([]uint64, len(st.counts)+grow, cap(st.counts)+grow)
+		copy(counts[grow:], st.counts)
+		st.counts = counts
+		st.offset = key
+	}
+	for key-st.offset >= len(st.counts) {
+		st.counts = append(st.counts, 0)
+	}
+
+	st.counts[key-st.offset] += n
+	st.total += n
+}
+
+// remove decrements a bucket, reporting false if it was already empty.
+func (st *sketchStore) remove(key int) bool {
+	i := key - st.offset
+	if i < 0 || i >= len(st.counts) || st.counts[i] == 0 {
+		return false
+	}
+	st.counts[i]--
+	st.total--
+	return true
+}
+
+// keyAtRank returns the key of the bucket holding the rank-th smallest value.
+func (st *sketchStore) keyAtRank(rank uint64) int {
+	var seen uint64
+	for i, c := range st.counts {
+		seen += c
+		if seen > rank {
+			return st.offset + i
+		}
+	}
+	return st.offset + len(st.counts) - 1
+}
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestSketch_Quantile(t *testing.T) {
+	s := NewSketch(0.01)
+	var values []float64
+	for i := 1; i <= 1000; i++ {
+		v := float64(i) * 1.5
+		values = append(values, v)
+		s.Add(v)
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0, 0.25, 0.5, 0.9, 0.95, 0.99, 1} {
+		want := values[int(q*float64(len(values)-1))]
+		got := s.Quantile(q)
+		if math.Abs(got-want)/want > 0.01 {
+			t.Errorf("Quantile(%v) = %v, want %v within 1%%", q, got, want)
+		}
+	}
+}
+
+func TestSketch_NegativeAndZero(t *testing.T) {
+	s := NewSketch(0.01)
+	for _, v := range []float64{-100, -10, 0, 10, 100} {
+		s.Add(v)
+	}
+
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0, -100},
+		{0.25, -10},
+		{0.5, 0},
+		{0.75, 10},
+		{1, 100},
+	}
+	for _, tt := range tests {
+		got := s.Quantile(tt.q)
+		if math.Abs(got-tt.want) > math.Abs(tt.want)*0.01 {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSketch_Remove(t *testing.T) {
+	s := NewSketch(0.01)
+	for i := 1; i <= 100; i++ {
+		s.Add(float64(i))
+	}
+	for i := 1; i <= 50; i++ {
+		s.Remove(float64(i))
+	}
+
+	if s.Count() != 50 {
+		t.Errorf("Count() = %d, want 50", s.Count())
+	}
+	if got := s.Quantile(0); math.Abs(got-51) > 51*0.01 {
+		t.Errorf("Quantile(0) = %v after removing 1-50, want ~51", got)
+	}
+
+	// Removing a value that was never added is ignored
+	s.Remove(1e6)
+	if s.Count() != 50 {
+		t.Errorf("Count() = %d after removing unknown value, want 50", s.Count())
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, b := NewSketch(0.01), NewSketch(0.01)
+	for i := 1; i <= 50; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 50))
+	}
+	a.Merge(b)
+
+	if a.Count() != 100 {
+		t.Errorf("Count() = %d, want 100", a.Count())
+	}
+	if got := a.Quantile(0.99); math.Abs(got-99) > 99*0.01 {
+		t.Errorf("Quantile(0.99) = %v, want ~99", got)
+	}
+}
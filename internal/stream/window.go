@@ -1,8 +1,13 @@
 package stream
 
 import (
+	"container/heap"
+	"iter"
+	"math"
 	"sync"
 	"time"
+
+	"github.com/danqzq/rift/internal/clock"
 )
 
 // WindowConfig holds configuration for window behavior.
@@ -10,30 +15,94 @@ type WindowConfig struct {
 	// MaxSize limits the number of points in a fixed-size window (0 means no limit)
 	MaxSize int
 
-	// TimeWindow limits points to those within this duration (0 means no limit)
+	// TimeWindow limits points to those that arrived within this duration,
+	// or with EventTime, whose timestamps are within it (0 means no limit)
 	TimeWindow time.Duration
+
+	// Rollup keeps downsampled buckets of evicted history at these
+	// resolutions, finest first (nil disables rollups)
+	Rollup []RollupTier
+
+	// EventTime evicts by the points' own timestamps instead of the wall
+	// clock. Points are held back until the watermark (newest timestamp
+	// seen minus AllowedLateness) passes them, so they enter the window in
+	// timestamp order; points older than the watermark are dropped as late.
+	EventTime       bool
+	AllowedLateness time.Duration
+
+	// Clock supplies the current time for arrival-time eviction and LastAdd
+	// (nil uses the system clock)
+	Clock clock.Clock
 }
 
-// Window manages a sliding window of data points with auto-scaling (thread-safe)
+// Window manages a sliding window of data points with auto-scaling (thread-safe).
+// Points are stored in a ring buffer and min/max are tracked with monotonic
+// queues, so adding a point is amortised O(1). Mean, variance and quantiles
+// are maintained incrementally as points enter and leave (see Stats).
 type Window struct {
 	mu     sync.RWMutex
-	points []DataPoint
+	buf    []DataPoint // ring buffer, oldest point at head
+	added  []time.Time // clock time each point in buf was added, by index
+	head   int
+	size   int
+	seq    uint64 // sequence number of the next point added
 	config WindowConfig
 
-	min float64
-	max float64
+	minQ monotonicQueue
+	maxQ monotonicQueue
+
+	// Running statistics, updated with Welford's algorithm on add and remove
+	sum      float64
+	mean     float64
+	m2       float64
+	sketch   *Sketch
+	removals int // removals since the running sums were last recomputed
+
+	rollup *Rollup // nil unless configured
+
+	// Event-time state
+	pending   pointHeap // points waiting for the watermark, oldest first
+	watermark time.Time
+	late      int
+
+	clock   clock.Clock
+	lastAdd time.Time // clock time of the last Add
+}
+
+// Stats summarizes the values currently in a window.
+type Stats struct {
+	Count    int
+	Sum      float64
+	Mean     float64
+	Variance float64 // population variance
+	StdDev   float64
+	Min      float64
+	Max      float64
+	P50      float64 // quantiles are estimates within 1% relative error
+	P90      float64
+	P95      float64
+	P99      float64
 }
 
 // NewWindow creates a new Window with the given configuration.
 func NewWindow(config WindowConfig) *Window {
 	capacity := config.MaxSize
 	if capacity <= 0 {
-		capacity = 1000 // default capacity for time-based windows
+		capacity = 1000 // initial capacity for time-based windows, grows as needed
 	}
-	return &Window{
-		points: make([]DataPoint, 0, capacity),
+	w := &Window{
+		buf:    make([]DataPoint, capacity),
+		added:  make([]time.Time, capacity),
 		config: config,
+		minQ:   monotonicQueue{better: func(a, b float64) bool { return a < b }},
+		maxQ:   monotonicQueue{better: func(a, b float64) bool { return a > b }},
+		sketch: NewSketch(0.01),
+		clock:  clock.Or(config.Clock),
+	}
+	if len(config.Rollup) > 0 {
+		w.rollup = NewRollup(config.Rollup)
 	}
+	return w
 }
 
 // NewFixedWindow creates a window that holds the last n points.
@@ -46,54 +115,215 @@ func NewTimeWindow(d time.Duration) *Window {
 	return NewWindow(WindowConfig{TimeWindow: d})
 }
 
-// Add inserts a new data point into the window.
+// Add inserts a new data point into the window. In event-time mode the
+// point may be held back until the watermark passes it, or dropped if late.
 func (w *Window) Add(p DataPoint) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.points = append(w.points, p)
+	w.lastAdd = w.clock.Now()
+	if !w.config.EventTime {
+		w.insertLocked(p)
+		w.evictLocked()
+		return
+	}
+
+	if !w.watermark.IsZero() && p.Timestamp.Before(w.watermark) {
+		w.late++
+		return
+	}
+	heap.Push(&w.pending, p)
+
+	if wm := p.Timestamp.Add(-w.config.AllowedLateness); wm.After(w.watermark) {
+		w.watermark = wm
+	}
+	w.releaseLocked(false)
+}
+
+// Expire evicts points that have aged out of a time-based window. Add only
+// evicts when new points arrive, so callers should Expire periodically (e.g.
+// before rendering) to keep quiet streams from showing old data forever.
+func (w *Window) Expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.evictLocked()
-	w.updateScaleLocked()
 }
 
-// evictLocked removes points that fall outside the window bounds (must be called with mu held)
-func (w *Window) evictLocked() {
-	if w.config.MaxSize > 0 && len(w.points) > w.config.MaxSize {
-		excess := len(w.points) - w.config.MaxSize
-		w.points = w.points[excess:]
+// LastAdd returns the clock time of the last Add, or the zero time if
+// nothing has been added.
+func (w *Window) LastAdd() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.lastAdd
+}
+
+// Flush moves all points held back for the watermark into the window.
+// Call it when the input ends. It has no effect outside event-time mode.
+func (w *Window) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.config.EventTime {
+		w.releaseLocked(true)
 	}
+}
 
-	if w.config.TimeWindow > 0 {
-		cutoff := time.Now().Add(-w.config.TimeWindow)
-		idx := 0
-		for idx < len(w.points) && w.points[idx].Timestamp.Before(cutoff) {
-			idx++
+// releaseLocked moves pending points up to the watermark (or all of them)
+// into the window in timestamp order (must be called with mu held)
+func (w *Window) releaseLocked(all bool) {
+	for w.pending.Len() > 0 && (all || !w.pending[0].Timestamp.After(w.watermark)) {
+		p := heap.Pop(&w.pending).(DataPoint)
+		if p.Timestamp.After(w.watermark) {
+			w.watermark = p.Timestamp
 		}
-		if idx > 0 {
-			w.points = w.points[idx:]
+		w.insertLocked(p)
+	}
+	w.evictLocked()
+}
+
+// insertLocked appends a point to the ring buffer, evicting the oldest point
+// if the window is full (must be called with mu held)
+func (w *Window) insertLocked(p DataPoint) {
+	if w.size == len(w.buf) {
+		if w.config.MaxSize > 0 {
+			w.removeOldestLocked()
+		} else {
+			w.growLocked()
 		}
 	}
+
+	i := (w.head + w.size) % len(w.buf)
+	w.buf[i] = p
+	w.added[i] = w.lastAdd
+	w.size++
+	w.minQ.push(w.seq, p.Value)
+	w.maxQ.push(w.seq, p.Value)
+	w.seq++
+	w.addStatsLocked(p.Value)
+	if w.rollup != nil {
+		w.rollup.Add(p)
+	}
 }
 
-// updateScaleLocked recalculates min/max from current points (must be called with mu held)
-func (w *Window) updateScaleLocked() {
-	if len(w.points) == 0 {
-		w.min = 0
-		w.max = 0
+// evictLocked removes points that fall outside the window bounds (must be
+// called with mu held). Points are aged by when they were added, since
+// timestamps parsed from the input may be far in the past, unless the
+// window runs on event time.
+func (w *Window) evictLocked() {
+	if w.config.TimeWindow <= 0 {
 		return
 	}
+	if w.config.EventTime {
+		cutoff := w.watermark.Add(-w.config.TimeWindow)
+		for w.size > 0 && w.buf[w.head].Timestamp.Before(cutoff) {
+			w.removeOldestLocked()
+		}
+		return
+	}
+	cutoff := w.clock.Now().Add(-w.config.TimeWindow)
+	for w.size > 0 && w.added[w.head].Before(cutoff) {
+		w.removeOldestLocked()
+	}
+}
 
-	w.min = w.points[0].Value
-	w.max = w.points[0].Value
+// removeOldestLocked drops the oldest point (must be called with mu held)
+func (w *Window) removeOldestLocked() {
+	oldest := w.seq - uint64(w.size)
+	w.minQ.evict(oldest)
+	w.maxQ.evict(oldest)
+	w.removeStatsLocked(w.buf[w.head].Value)
 
-	for _, p := range w.points[1:] {
-		if p.Value < w.min {
-			w.min = p.Value
-		}
-		if p.Value > w.max {
-			w.max = p.Value
-		}
+	w.buf[w.head] = DataPoint{} // release references held by the point
+	w.added[w.head] = time.Time{}
+	w.head = (w.head + 1) % len(w.buf)
+	w.size--
+}
+
+// addStatsLocked folds a new value into the running statistics (must be called with mu held)
+func (w *Window) addStatsLocked(v float64) {
+	n := float64(w.size)
+	delta := v - w.mean
+	w.sum += v
+	w.mean += delta / n
+	w.m2 += delta * (v - w.mean)
+	w.sketch.Add(v)
+}
+
+// removeStatsLocked takes an evicted value out of the running statistics.
+// Must be called with mu held, before size is decremented.
+func (w *Window) removeStatsLocked(v float64) {
+	w.sketch.Remove(v)
+
+	if w.size <= 1 {
+		w.sum, w.mean, w.m2 = 0, 0, 0
+		return
+	}
+
+	n := float64(w.size - 1)
+	delta := v - w.mean
+	w.sum -= v
+	w.mean -= delta / n
+	w.m2 -= delta * (v - w.mean)
+
+	// Subtracting values accumulates rounding error, so recompute the sums
+	// exactly once per buffer's worth of removals to keep them honest
+	w.removals++
+	if w.removals >= len(w.buf) {
+		w.recomputeStatsLocked()
+	}
+}
+
+// recomputeStatsLocked rebuilds sum, mean and m2 from the points still in the
+// window, skipping the oldest one, which is about to be removed (must be called with mu held)
+func (w *Window) recomputeStatsLocked() {
+	w.removals = 0
+	w.sum, w.mean, w.m2 = 0, 0, 0
+	for i := 1; i < w.size; i++ {
+		v := w.atLocked(i).Value
+		delta := v - w.mean
+		w.sum += v
+		w.mean += delta / float64(i)
+		w.m2 += delta * (v - w.mean)
+	}
+}
+
+// resetStatsLocked clears the running statistics (must be called with mu held)
+func (w *Window) resetStatsLocked() {
+	w.sum, w.mean, w.m2 = 0, 0, 0
+	w.removals = 0
+	w.sketch.Clear()
+}
+
+// growLocked doubles the ring buffer capacity (must be called with mu held)
+func (w *Window) growLocked() {
+	buf := make([]DataPoint, len(w.buf)*2)
+	added := make([]time.Time, len(buf))
+	copyRing(buf, w.buf, w.head, w.size)
+	copyRing(added, w.added, w.head, w.size)
+	w.buf, w.added = buf, added
+	w.head = 0
+}
+
+// copyLocked copies the points in order into dst (must be called with mu held)
+func (w *Window) copyLocked(dst []DataPoint) {
+	copyRing(dst, w.buf, w.head, w.size)
+}
+
+// copyRing copies the size elements of a ring buffer starting at head into
+// dst, oldest first.
+func copyRing[T any](dst, ring []T, head, size int) {
+	end := head + size
+	if end <= len(ring) {
+		copy(dst, ring[head:end])
+		return
 	}
+	n := copy(dst, ring[head:])
+	copy(dst[n:], ring[:end-len(ring)])
+}
+
+// atLocked returns the i-th oldest point (must be called with mu held)
+func (w *Window) atLocked(i int) DataPoint {
+	return w.buf[(w.head+i)%len(w.buf)]
 }
 
 // Points returns a copy of all points in the window
@@ -101,37 +331,65 @@ func (w *Window) Points() []DataPoint {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	result := make([]DataPoint, len(w.points))
-	copy(result, w.points)
+	result := make([]DataPoint, w.size)
+	w.copyLocked(result)
 	return result
 }
 
+// All iterates over the points in the window, oldest first, without copying.
+// The window is read-locked during iteration, so the loop body must not call
+// other Window methods.
+func (w *Window) All() iter.Seq[DataPoint] {
+	return w.Tail(-1)
+}
+
+// Tail iterates over the newest n points, oldest first, without copying.
+// A negative n iterates over all points. The same locking rules as All apply.
+func (w *Window) Tail(n int) iter.Seq[DataPoint] {
+	return func(yield func(DataPoint) bool) {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+
+		start := 0
+		if n >= 0 && n < w.size {
+			start = w.size - n
+		}
+		for i := start; i < w.size; i++ {
+			if !yield(w.atLocked(i)) {
+				return
+			}
+		}
+	}
+}
+
 // Len returns the number of points currently in the window
 func (w *Window) Len() int {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
-	return len(w.points)
+	return w.size
 }
 
 // Min returns the minimum value in the current window.
 func (w *Window) Min() float64 {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	return w.min
+	min, _ := w.Scale()
+	return min
 }
 
 // Max returns the maximum value in the current window.
 func (w *Window) Max() float64 {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	return w.max
+	_, max := w.Scale()
+	return max
 }
 
 // Scale returns both min and max for efficient access.
 func (w *Window) Scale() (min, max float64) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
-	return w.min, w.max
+
+	if w.size == 0 {
+		return 0, 0
+	}
+	return w.minQ.front().value, w.maxQ.front().value
 }
 
 // Clear removes all points from the window.
@@ -139,9 +397,51 @@ func (w *Window) Clear() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	w.points = w.points[:0]
-	w.min = 0
-	w.max = 0
+	clear(w.buf)
+	clear(w.added)
+	w.head = 0
+	w.size = 0
+	w.minQ.reset()
+	w.maxQ.reset()
+	w.resetStatsLocked()
+	if w.rollup != nil {
+		w.rollup.Clear()
+	}
+	w.pending = w.pending[:0]
+	w.watermark = time.Time{}
+}
+
+// Watermark returns the event time up to which the window is complete,
+// or the zero time outside event-time mode.
+func (w *Window) Watermark() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.watermark
+}
+
+// Pending returns the number of points held back for the watermark.
+func (w *Window) Pending() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.pending.Len()
+}
+
+// Late returns the number of points dropped for arriving behind the watermark.
+func (w *Window) Late() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.late
+}
+
+// First returns the oldest data point, or an empty DataPoint if the window is empty.
+func (w *Window) First() (DataPoint, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.size == 0 {
+		return DataPoint{}, false
+	}
+	return w.atLocked(0), true
 }
 
 // Last returns the most recent data point, or an empty DataPoint if the window is empty.
@@ -149,8 +449,95 @@ func (w *Window) Last() (DataPoint, bool) {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	if len(w.points) == 0 {
+	if w.size == 0 {
 		return DataPoint{}, false
 	}
-	return w.points[len(w.points)-1], true
+	return w.atLocked(w.size - 1), true
+}
+
+// Stats returns count, sum, mean, variance, min/max and quantile estimates
+// for the points in the window, without scanning them.
+func (w *Window) Stats() Stats {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.size == 0 {
+		return Stats{}
+	}
+
+	variance := math.Max(w.m2/float64(w.size), 0)
+	return Stats{
+		Count:    w.size,
+		Sum:      w.sum,
+		Mean:     w.mean,
+		Variance: variance,
+		StdDev:   math.Sqrt(variance),
+		Min:      w.minQ.front().value,
+		Max:      w.maxQ.front().value,
+		P50:      w.quantileLocked(0.50),
+		P90:      w.quantileLocked(0.90),
+		P95:      w.quantileLocked(0.95),
+		P99:      w.quantileLocked(0.99),
+	}
+}
+
+// Quantile returns an estimate of the q-th quantile (0-1) of the values in
+// the window, within 1% relative error.
+func (w *Window) Quantile(q float64) float64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.quantileLocked(q)
+}
+
+// quantileLocked estimates a quantile, clamped to the exact min/max (must be called with mu held)
+func (w *Window) quantileLocked(q float64) float64 {
+	if w.size == 0 {
+		return 0
+	}
+	v := w.sketch.Quantile(q)
+	return math.Max(w.minQ.front().value, math.Min(v, w.maxQ.front().value))
+}
+
+// Range summarizes the points between from and to (inclusive) in columns
+// buckets of equal width. Raw points are used while the window still holds the whole
+// range; older ranges are served from the rollup tiers, if configured.
+func (w *Window) Range(from, to time.Time, columns int) []Bucket {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	evicted := w.seq > uint64(w.size)
+	if w.rollup != nil && evicted && (w.size == 0 || w.atLocked(0).Timestamp.After(from)) {
+		return w.rollup.Range(from, to, columns)
+	}
+
+	result := newColumns(from, to, columns)
+	if result == nil {
+		return nil
+	}
+
+	width := to.Sub(from) / time.Duration(columns)
+	for i := 0; i < w.size; i++ {
+		p := w.atLocked(i)
+		if p.Timestamp.Before(from) || p.Timestamp.After(to) {
+			continue
+		}
+		result[columnIndex(p.Timestamp, from, width, columns)].add(p)
+	}
+	return result
+}
+
+// pointHeap orders points by timestamp for event-time release.
+type pointHeap []DataPoint
+
+func (h pointHeap) Len() int           { return len(h) }
+func (h pointHeap) Less(i, j int) bool { return h[i].Timestamp.Before(h[j].Timestamp) }
+func (h pointHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pointHeap) Push(x any)        { *h = append(*h, x.(DataPoint)) }
+
+func (h *pointHeap) Pop() any {
+	old := *h
+	p := old[len(old)-1]
+	old[len(old)-1] = DataPoint{}
+	*h = old[:len(old)-1]
+	return p
 }
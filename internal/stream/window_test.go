@@ -1,8 +1,11 @@
 package stream
 
 import (
+	"math"
 	"testing"
 	"time"
+
+	"github.com/danqzq/rift/internal/clock"
 )
 
 func TestNewFixedWindow(t *testing.T) {
@@ -47,19 +50,20 @@ func TestWindow_FixedSizeEviction(t *testing.T) {
 }
 
 func TestWindow_TimeBasedEviction(t *testing.T) {
-	w := NewTimeWindow(100 * time.Millisecond)
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := NewWindow(WindowConfig{TimeWindow: 100 * time.Millisecond, Clock: clk})
 
 	// Add a point
-	w.Add(NewDataPoint(1))
+	w.Add(NewDataPointAt(1, clk.Now()))
 	if w.Len() != 1 {
 		t.Errorf("expected 1 point, got %d", w.Len())
 	}
 
-	// Wait for the time window to expire
-	time.Sleep(150 * time.Millisecond)
+	// Move past the time window
+	clk.Advance(150 * time.Millisecond)
 
 	// Add another point, which should trigger eviction of the old one
-	w.Add(NewDataPoint(2))
+	w.Add(NewDataPointAt(2, clk.Now()))
 
 	if w.Len() != 1 {
 		t.Errorf("expected 1 point after time eviction, got %d", w.Len())
@@ -71,6 +75,45 @@ func TestWindow_TimeBasedEviction(t *testing.T) {
 	}
 }
 
+func TestWindow_Expire(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	w := NewWindow(WindowConfig{TimeWindow: time.Minute, Clock: clk})
+	w.Add(pointAt(now, 1))
+	clk.Advance(30 * time.Second)
+	w.Add(pointAt(clk.Now(), 2))
+
+	// Add evicts too, but Expire must work without new input
+	clk.Advance(45 * time.Second)
+	w.Expire()
+	if w.Len() != 1 {
+		t.Fatalf("expected 1 point after Expire, got %d", w.Len())
+	}
+	if last, want := w.LastAdd(), now.Add(30*time.Second); !last.Equal(want) {
+		t.Errorf("LastAdd = %v, expected clock time of the last Add %v", last, want)
+	}
+}
+
+func TestWindow_EvictsByArrivalTime(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	w := NewWindow(WindowConfig{TimeWindow: time.Minute, Clock: clk})
+
+	// Timestamps from old logs must not age points that just arrived
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.Add(pointAt(old, 1))
+	w.Add(pointAt(old.Add(time.Second), 2))
+	if w.Len() != 2 {
+		t.Fatalf("expected 2 old-dated points to be kept, got %d", w.Len())
+	}
+
+	clk.Advance(2 * time.Minute)
+	w.Expire()
+	if w.Len() != 0 {
+		t.Errorf("expected points to expire a minute after they arrived, got %d", w.Len())
+	}
+}
+
 func TestWindow_MinMax(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -184,3 +227,244 @@ func TestWindow_Points_IsCopy(t *testing.T) {
 		t.Error("Points() should return a copy, not the original slice")
 	}
 }
+
+func TestWindow_SlidingMinMax(t *testing.T) {
+	w := NewFixedWindow(3)
+	values := []float64{5, 1, 3, 4, 6, 2, 2, 9}
+	// Expected min/max of the last 3 values after each Add
+	wantMin := []float64{5, 1, 1, 1, 3, 2, 2, 2}
+	wantMax := []float64{5, 5, 5, 4, 6, 6, 6, 9}
+
+	for i, v := range values {
+		w.Add(NewDataPoint(v))
+		min, max := w.Scale()
+		if min != wantMin[i] || max != wantMax[i] {
+			t.Errorf("after adding %v: Scale() = %v/%v, want %v/%v", v, min, max, wantMin[i], wantMax[i])
+		}
+	}
+}
+
+func TestWindow_RingWrapAround(t *testing.T) {
+	w := NewFixedWindow(4)
+	for i := 1; i <= 10; i++ {
+		w.Add(NewDataPoint(float64(i)))
+	}
+
+	var got []float64
+	for p := range w.All() {
+		got = append(got, p.Value)
+	}
+	want := []float64{7, 8, 9, 10}
+	if len(got) != len(want) {
+		t.Fatalf("All() yielded %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("All() yielded %v, want %v", got, want)
+		}
+	}
+
+	first, _ := w.First()
+	if first.Value != 7 {
+		t.Errorf("First() = %v, want 7", first.Value)
+	}
+}
+
+func TestWindow_Tail(t *testing.T) {
+	w := NewFixedWindow(10)
+	for i := 1; i <= 5; i++ {
+		w.Add(NewDataPoint(float64(i)))
+	}
+
+	tests := []struct {
+		n    int
+		want []float64
+	}{
+		{2, []float64{4, 5}},
+		{0, nil},
+		{10, []float64{1, 2, 3, 4, 5}},
+		{-1, []float64{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		var got []float64
+		for p := range w.Tail(tt.n) {
+			got = append(got, p.Value)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Tail(%d) = %v, want %v", tt.n, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Tail(%d) = %v, want %v", tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestWindow_TimeWindowGrows(t *testing.T) {
+	w := NewTimeWindow(time.Hour)
+	for i := 0; i < 2500; i++ {
+		w.Add(NewDataPoint(float64(i)))
+	}
+
+	if w.Len() != 2500 {
+		t.Errorf("expected 2500 points, got %d", w.Len())
+	}
+	min, max := w.Scale()
+	if min != 0 || max != 2499 {
+		t.Errorf("Scale() = %v/%v, want 0/2499", min, max)
+	}
+	points := w.Points()
+	if points[0].Value != 0 || points[2499].Value != 2499 {
+		t.Errorf("points out of order after growing: first %v, last %v", points[0].Value, points[2499].Value)
+	}
+}
+
+func BenchmarkWindow_Add(b *testing.B) {
+	w := NewFixedWindow(1000)
+	p := NewDataPoint(0)
+	for i := 0; i < b.N; i++ {
+		p.Value = float64(i % 997)
+		w.Add(p)
+	}
+}
+
+func BenchmarkWindow_AddTimeWindow(b *testing.B) {
+	w := NewTimeWindow(time.Minute)
+	p := NewDataPoint(0)
+	for i := 0; i < b.N; i++ {
+		p.Value = float64(i % 997)
+		p.Timestamp = time.Now()
+		w.Add(p)
+	}
+}
+
+func BenchmarkWindow_Points(b *testing.B) {
+	w := NewFixedWindow(1000)
+	for i := 0; i < 1000; i++ {
+		w.Add(NewDataPoint(float64(i)))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sum := 0.0
+		for _, p := range w.Points() {
+			sum += p.Value
+		}
+	}
+}
+
+func BenchmarkWindow_All(b *testing.B) {
+	w := NewFixedWindow(1000)
+	for i := 0; i < 1000; i++ {
+		w.Add(NewDataPoint(float64(i)))
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sum := 0.0
+		for p := range w.All() {
+			sum += p.Value
+		}
+	}
+}
+
+func TestWindow_Stats(t *testing.T) {
+	w := NewFixedWindow(4)
+	for _, v := range []float64{100, 100, 2, 4, 4, 6} {
+		w.Add(NewDataPoint(v))
+	}
+
+	// Window now holds 2, 4, 4, 6
+	s := w.Stats()
+	if s.Count != 4 || math.Abs(s.Sum-16) > 1e-9 || math.Abs(s.Mean-4) > 1e-9 {
+		t.Errorf("Count/Sum/Mean = %d/%v/%v, want 4/16/4", s.Count, s.Sum, s.Mean)
+	}
+	if math.Abs(s.Variance-2) > 1e-9 {
+		t.Errorf("Variance = %v, want 2", s.Variance)
+	}
+	if s.Min != 2 || s.Max != 6 {
+		t.Errorf("Min/Max = %v/%v, want 2/6", s.Min, s.Max)
+	}
+	if math.Abs(s.P50-4) > 0.04 {
+		t.Errorf("P50 = %v, want ~4", s.P50)
+	}
+
+	w.Clear()
+	if s := w.Stats(); s != (Stats{}) {
+		t.Errorf("expected zero Stats after Clear, got %+v", s)
+	}
+}
+
+func TestWindow_StatsLongRun(t *testing.T) {
+	w := NewFixedWindow(100)
+	for i := 0; i < 100000; i++ {
+		w.Add(NewDataPoint(1e6 + float64(i%10)))
+	}
+
+	// Last 100 values cycle through 1e6+0..9
+	s := w.Stats()
+	if math.Abs(s.Mean-(1e6+4.5)) > 1e-6 {
+		t.Errorf("Mean drifted: %v", s.Mean)
+	}
+	if math.Abs(s.Variance-8.25) > 1e-6 {
+		t.Errorf("Variance drifted: %v, want 8.25", s.Variance)
+	}
+}
+
+func TestWindow_EventTimeEviction(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWindow(WindowConfig{TimeWindow: time.Minute, EventTime: true})
+
+	// Yesterday's timestamps are not evicted against the wall clock
+	for s := 0; s <= 120; s += 10 {
+		w.Add(pointAt(base.Add(time.Duration(s)*time.Second), float64(s)))
+	}
+
+	first, _ := w.First()
+	if want := base.Add(60 * time.Second); !first.Timestamp.Equal(want) {
+		t.Errorf("oldest point at %v, want %v", first.Timestamp, want)
+	}
+	if w.Len() != 7 {
+		t.Errorf("expected 7 points in the last minute of event time, got %d", w.Len())
+	}
+}
+
+func TestWindow_EventTimeLateness(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := NewWindow(WindowConfig{MaxSize: 100, EventTime: true, AllowedLateness: 5 * time.Second})
+
+	// Out-of-order points within the allowed lateness are reordered
+	for _, s := range []int{0, 3, 1, 2, 10, 6, 12} {
+		w.Add(pointAt(base.Add(time.Duration(s)*time.Second), float64(s)))
+	}
+
+	// Watermark is 12s-5s, so points up to 7s are released
+	if w.Len() != 5 || w.Pending() != 2 {
+		t.Errorf("Len/Pending = %d/%d, want 5/2", w.Len(), w.Pending())
+	}
+
+	// A point behind the watermark is dropped
+	w.Add(pointAt(base.Add(4*time.Second), 4))
+	if w.Late() != 1 {
+		t.Errorf("Late() = %d, want 1", w.Late())
+	}
+
+	w.Flush()
+	var got []float64
+	for p := range w.All() {
+		got = append(got, p.Value)
+	}
+	want := []float64{0, 1, 2, 3, 6, 10, 12}
+	if len(got) != len(want) {
+		t.Fatalf("points = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("points = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Package sys collects Linux system metrics from /proc and /sys: CPU,
+// memory, network, disk, load and pressure, plus CPU and memory of a single
+// process. Counters are turned into rates and percentages between
+// collections, so the first collection only reports gauges.
+package sys
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userHZ is the kernel's clock tick rate for CPU times in /proc, which is
+// 100 on every mainstream Linux architecture.
+const userHZ = 100
+
+// sectorSize is the unit of the sector counts in /proc/diskstats.
+const sectorSize = 512
+
+// Collector reads system metrics. It keeps the previous counter values to
+// compute rates, so use one Collector per stream of collections.
+type Collector struct {
+	Proc string // procfs mount point, "/proc" by default
+	Sys  string // sysfs mount point, "/sys" by default
+	PID  int    // process to track as proc.*, or 0 for none
+
+	prev *snapshot
+}
+
+// NewCollector creates a collector for the running system, tracking pid if
+// it is not 0.
+func NewCollector(pid int) *Collector {
+	return &Collector{Proc: "/proc", Sys: "/sys", PID: pid}
+}
+
+// snapshot holds the counters of one collection.
+type snapshot struct {
+	at       time.Time
+	cpu      cpuTimes
+	ctxt     uint64
+	net      map[string]netCounters
+	disk     map[string]diskCounters
+	procTime uint64 // utime+stime of PID in ticks
+}
+
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal uint64
+}
+
+func (c cpuTimes) total() uint64 {
+	return c.user + c.nice + c.system + c.idle + c.iowait + c.irq + c.softirq + c.steal
+}
+
+type netCounters struct {
+	rxBytes, rxPackets, txBytes, txPackets, errors uint64
+}
+
+type diskCounters struct {
+	reads, readSectors, writes, writeSectors, ioMillis uint64
+}
+
+// Collect reads every metric source and returns values keyed by label, such
+// as "cpu.user" or "net.eth0.rx_bytes_per_s". Sources missing on this
+// kernel are skipped; it fails only if /proc cannot be read at all or the
+// tracked process has gone.
+func (c *Collector) Collect(now time.Time) (map[string]float64, error) {
+	m := make(map[string]float64)
+	cur := &snapshot{at: now}
+	var elapsed float64
+	if c.prev != nil {
+		elapsed = now.Sub(c.prev.at).Seconds()
+	}
+	rate := func(label string, cur, prev uint64) {
+		if elapsed > 0 && cur >= prev {
+			m[label] = float64(cur-prev) / elapsed
+		}
+	}
+
+	if err := c.readStat(cur); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", c.path(c.Proc, "stat"), err)
+	}
+	if c.prev != nil {
+		cpuPercents(m, cur.cpu, c.prev.cpu)
+		rate("cpu.ctxt_per_s", cur.ctxt, c.prev.ctxt)
+	}
+
+	// The remaining sources are optional
+	readers := []func(*snapshot, map[string]float64) error{
+		c.readMeminfo, c.readLoadavg, c.readPressure, c.readNetDev, c.readDiskstats,
+	}
+	for _, read := range readers {
+		if err := read(cur, m); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if c.prev != nil {
+		var rx, tx float64
+		for name, n := range cur.net {
+			p, ok := c.prev.net[name]
+			if !ok {
+				continue
+			}
+			prefix := "net." + name + "."
+			rate(prefix+"rx_bytes_per_s", n.rxBytes, p.rxBytes)
+			rate(prefix+"tx_bytes_per_s", n.txBytes, p.txBytes)
+			rate(prefix+"rx_packets_per_s", n.rxPackets, p.rxPackets)
+			rate(prefix+"tx_packets_per_s", n.txPackets, p.txPackets)
+			rate(prefix+"errors_per_s", n.errors, p.errors)
+			if name != "lo" {
+				rx += m[prefix+"rx_bytes_per_s"]
+				tx += m[prefix+"tx_bytes_per_s"]
+			}
+		}
+		if elapsed > 0 {
+			m["net.rx_bytes_per_s"], m["net.tx_bytes_per_s"] = rx, tx
+		}
+
+		var read, written float64
+		for name, d := range cur.disk {
+			p, ok := c.prev.disk[name]
+			if !ok {
+				continue
+			}
+			prefix := "disk." + name + "."
+			rate(prefix+"reads_per_s", d.reads, p.reads)
+			rate(prefix+"writes_per_s", d.writes, p.writes)
+			rate(prefix+"read_bytes_per_s", d.readSectors*sectorSize, p.readSectors*sectorSize)
+			rate(prefix+"write_bytes_per_s", d.writeSectors*sectorSize, p.writeSectors*sectorSize)
+			if elapsed > 0 && d.ioMillis >= p.ioMillis {
+				m[prefix+"util"] = min(100, float64(d.ioMillis-p.ioMillis)/(elapsed*1000)*100)
+			}
+			read += m[prefix+"read_bytes_per_s"]
+			written += m[prefix+"write_bytes_per_s"]
+		}
+		if elapsed > 0 {
+			m["disk.read_bytes_per_s"], m["disk.write_bytes_per_s"] = read, written
+		}
+	}
+
+	if c.PID != 0 {
+		if err := c.readProcess(cur, m); err != nil {
+			return nil, err
+		}
+		if c.prev != nil && elapsed > 0 && cur.procTime >= c.prev.procTime {
+			m["proc.cpu"] = float64(cur.procTime-c.prev.procTime) / userHZ / elapsed * 100
+		}
+	}
+
+	c.prev = cur
+	return m, nil
+}
+
+// cpuPercents sets cpu.* to the share of CPU time spent in each state since
+// the previous collection, in percent of all CPUs.
+func cpuPercents(m map[string]float64, cur, prev cpuTimes) {
+	total := float64(cur.total() - prev.total())
+	if total <= 0 {
+		return
+	}
+	pct := func(cur, prev uint64) float64 {
+		return float64(cur-prev) / total * 100
+	}
+	m["cpu.user"] = pct(cur.user+cur.nice, prev.user+prev.nice)
+	m["cpu.system"] = pct(cur.system+cur.irq+cur.softirq, prev.system+prev.irq+prev.softirq)
+	m["cpu.iowait"] = pct(cur.iowait, prev.iowait)
+	m["cpu.steal"] = pct(cur.steal, prev.steal)
+	m["cpu.idle"] = pct(cur.idle, prev.idle)
+	m["cpu.busy"] = 100 - m["cpu.idle"] - m["cpu.iowait"]
+}
+
+func (c *Collector) path(root string, elem ...string) string {
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// readStat reads the aggregate CPU times, context switches and process
+// counts from /proc/stat.
+func (c *Collector) readStat(s *snapshot) error {
+	data, err := os.ReadFile(c.path(c.Proc, "stat"))
+	if err != nil {
+		return err
+	}
+	for line := range strings.Lines(string(data)) {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "cpu":
+			v := parseUints(f[1:])
+			for len(v) < 8 {
+				v = append(v, 0)
+			}
+			s.cpu = cpuTimes{v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]}
+		case "ctxt":
+			s.ctxt, _ = strconv.ParseUint(f[1], 10, 64)
+		}
+	}
+	return nil
+}
+
+// readMeminfo reads memory and swap use from /proc/meminfo.
+func (c *Collector) readMeminfo(_ *snapshot, m map[string]float64) error {
+	data, err := os.ReadFile(c.path(c.Proc, "meminfo"))
+	if err != nil {
+		return err
+	}
+	kb := make(map[string]float64)
+	for line := range strings.Lines(string(data)) {
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		f := strings.Fields(rest)
+		if len(f) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(f[0], 64); err == nil {
+			kb[key] = v
+		}
+	}
+
+	total := kb["MemTotal"] * 1024
+	avail, ok := kb["MemAvailable"]
+	if !ok {
+		// Kernels before 3.14 lack MemAvailable
+		avail = kb["MemFree"] + kb["Buffers"] + kb["Cached"]
+	}
+	avail *= 1024
+	m["mem.total_bytes"] = total
+	m["mem.available_bytes"] = avail
+	m["mem.used_bytes"] = total - avail
+	if total > 0 {
+		m["mem.used_pct"] = (total - avail) / total * 100
+	}
+	if swap := kb["SwapTotal"] * 1024; swap > 0 {
+		used := swap - kb["SwapFree"]*1024
+		m["mem.swap_used_bytes"] = used
+		m["mem.swap_used_pct"] = used / swap * 100
+	}
+	return nil
+}
+
+// readLoadavg reads the load averages from /proc/loadavg.
+func (c *Collector) readLoadavg(_ *snapshot, m map[string]float64) error {
+	data, err := os.ReadFile(c.path(c.Proc, "loadavg"))
+	if err != nil {
+		return err
+	}
+	f := strings.Fields(string(data))
+	for i, label := range []string{"load.1", "load.5", "load.15"} {
+		if i < len(f) {
+			if v, err := strconv.ParseFloat(f[i], 64); err == nil {
+				m[label] = v
+			}
+		}
+	}
+	return nil
+}
+
+// readPressure reads pressure stall averages from /proc/pressure/*, as
+// psi.<resource>.<some|full>_avg<10|60|300>.
+func (c *Collector) readPressure(_ *snapshot, m map[string]float64) error {
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		data, err := os.ReadFile(c.path(c.Proc, "pressure", resource))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		for line := range strings.Lines(string(data)) {
+			f := strings.Fields(line)
+			if len(f) == 0 {
+				continue
+			}
+			for _, kv := range f[1:] {
+				key, val, _ := strings.Cut(kv, "=")
+				if !strings.HasPrefix(key, "avg") {
+					continue
+				}
+				if v, err := strconv.ParseFloat(val, 64); err == nil {
+					m["psi."+resource+"."+f[0]+"_"+key] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readNetDev reads per-interface traffic counters from /proc/net/dev.
+func (c *Collector) readNetDev(s *snapshot, _ map[string]float64) error {
+	data, err := os.ReadFile(c.path(c.Proc, "net", "dev"))
+	if err != nil {
+		return err
+	}
+	s.net = make(map[string]netCounters)
+	for line := range strings.Lines(string(data)) {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		v := parseUints(strings.Fields(rest))
+		if len(v) < 11 {
+			continue
+		}
+		s.net[strings.TrimSpace(name)] = netCounters{
+			rxBytes: v[0], rxPackets: v[1], txBytes: v[8], txPackets: v[9],
+			errors: v[2] + v[10],
+		}
+	}
+	return nil
+}
+
+// readDiskstats reads I/O counters of whole disks from /proc/diskstats.
+// Partitions are left out using /sys/block, which lists only whole disks;
+// without sysfs, loop and ram devices are still left out.
+func (c *Collector) readDiskstats(s *snapshot, _ map[string]float64) error {
+	data, err := os.ReadFile(c.path(c.Proc, "diskstats"))
+	if err != nil {
+		return err
+	}
+	var disks map[string]bool
+	if entries, err := os.ReadDir(c.path(c.Sys, "block")); err == nil {
+		disks = make(map[string]bool, len(entries))
+		for _, e := range entries {
+			disks[e.Name()] = true
+		}
+	}
+
+	s.disk = make(map[string]diskCounters)
+	for line := range strings.Lines(string(data)) {
+		f := strings.Fields(line)
+		if len(f) < 14 {
+			continue
+		}
+		name := f[2]
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		if disks != nil && !disks[name] {
+			continue
+		}
+		v := parseUints(f[3:])
+		s.disk[name] = diskCounters{
+			reads: v[0], readSectors: v[2], writes: v[4], writeSectors: v[6], ioMillis: v[9],
+		}
+	}
+	return nil
+}
+
+// readProcess reads the tracked process's CPU time from /proc/<pid>/stat and
+// its memory and threads from /proc/<pid>/status.
+func (c *Collector) readProcess(s *snapshot, m map[string]float64) error {
+	pid := strconv.Itoa(c.PID)
+	data, err := os.ReadFile(c.path(c.Proc, pid, "stat"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("process %d not found", c.PID)
+	}
+	if err != nil {
+		return err
+	}
+	// The command name is in parentheses and may contain spaces, so split
+	// the fields after it; state is the first
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return fmt.Errorf("process %d: malformed stat", c.PID)
+	}
+	f := strings.Fields(string(data[i+1:]))
+	if len(f) < 13 {
+		return fmt.Errorf("process %d: malformed stat", c.PID)
+	}
+	v := parseUints(f[11:13]) // utime, stime
+	s.procTime = v[0] + v[1]
+
+	status, err := os.Open(c.path(c.Proc, pid, "status"))
+	if err != nil {
+		return err
+	}
+	defer status.Close()
+	sc := bufio.NewScanner(status)
+	for sc.Scan() {
+		key, rest, _ := strings.Cut(sc.Text(), ":")
+		f := strings.Fields(rest)
+		if len(f) == 0 {
+			continue
+		}
+		n, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "VmRSS":
+			m["proc.rss_bytes"] = n * 1024
+		case "Threads":
+			m["proc.threads"] = n
+		}
+	}
+	return sc.Err()
+}
+
+// parseUints parses fields as unsigned integers, using 0 for bad fields.
+func parseUints(fields []string) []uint64 {
+	v := make([]uint64, len(fields))
+	for i, f := range fields {
+		v[i], _ = strconv.ParseUint(f, 10, 64)
+	}
+	return v
+}
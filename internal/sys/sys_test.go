@@ -0,0 +1,84 @@
+package sys
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCollector_Fixtures(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &Collector{Proc: "testdata/t0/proc", Sys: "testdata/sys", PID: 42}
+
+	first, err := c.Collect(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rates need two collections, so only gauges come first
+	if _, ok := first["cpu.user"]; ok {
+		t.Error("first collection should not report CPU percentages")
+	}
+
+	c.Proc = "testdata/t1/proc"
+	got, err := c.Collect(start.Add(2 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		label string
+		want  float64
+	}{
+		{"cpu.user", 25},
+		{"cpu.system", 15},
+		{"cpu.iowait", 10},
+		{"cpu.idle", 50},
+		{"cpu.busy", 40},
+		{"cpu.ctxt_per_s", 1000},
+		{"mem.total_bytes", 1000000 * 1024},
+		{"mem.used_pct", 75},
+		{"mem.swap_used_pct", 25},
+		{"load.1", 1.5},
+		{"load.15", 0.5},
+		{"net.eth0.rx_bytes_per_s", 1000},
+		{"net.eth0.tx_packets_per_s", 5},
+		{"net.eth0.errors_per_s", 1},
+		{"net.rx_bytes_per_s", 1000}, // lo is left out of the total
+		{"disk.sda.reads_per_s", 100},
+		{"disk.sda.read_bytes_per_s", 4000 * 512 / 2},
+		{"disk.sda.write_bytes_per_s", 400 * 512 / 2},
+		{"disk.sda.util", 50},
+		{"disk.read_bytes_per_s", 4000 * 512 / 2},
+		{"proc.cpu", 100},
+		{"proc.rss_bytes", 2048 * 1024},
+		{"proc.threads", 4},
+	}
+	for _, tt := range tests {
+		v, ok := got[tt.label]
+		if !ok {
+			t.Errorf("%s missing", tt.label)
+			continue
+		}
+		if math.Abs(v-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.label, v, tt.want)
+		}
+	}
+
+	if v := first["psi.cpu.some_avg10"]; v != 2.5 {
+		t.Errorf("psi.cpu.some_avg10 = %v, want 2.5", v)
+	}
+	for _, label := range []string{"disk.sda1.reads_per_s", "disk.loop0.reads_per_s", "psi.cpu.some_avg10"} {
+		if _, ok := got[label]; ok {
+			t.Errorf("%s should not be reported", label)
+		}
+	}
+}
+
+func TestCollector_Errors(t *testing.T) {
+	if _, err := (&Collector{Proc: "testdata/missing"}).Collect(time.Now()); err == nil {
+		t.Error("expected error without /proc/stat")
+	}
+	if _, err := (&Collector{Proc: "testdata/t0/proc", PID: 7}).Collect(time.Now()); err == nil {
+		t.Error("expected error for a missing process")
+	}
+}
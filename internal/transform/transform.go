@@ -0,0 +1,317 @@
+// Package transform rewrites data point values before they reach a window:
+// rates of counters, smoothing, cumulative sums and simple arithmetic.
+package transform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// Transform rewrites a data point. It returns false to drop the point, for
+// example the first point of a rate, which has nothing to compare against.
+type Transform interface {
+	Apply(p stream.DataPoint) (stream.DataPoint, bool)
+}
+
+// Pipeline applies transforms in order.
+type Pipeline []Transform
+
+// Apply runs the point through every transform, stopping if one drops it.
+func (pl Pipeline) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	for _, t := range pl {
+		var ok bool
+		if p, ok = t.Apply(p); !ok {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// Parse builds a pipeline from a spec such as "rate|ewma(0.3)|scale(8)".
+// Stages are separated by "|" and take optional comma-separated arguments in
+// parentheses. An empty spec returns an empty pipeline.
+//
+// Stages:
+//
+//	rate          per-second rate of a monotonic counter, treating decreases as resets
+//	delta         change from the previous value
+//	sma(n)        simple moving average of the last n values
+//	ewma(alpha)   exponentially weighted moving average, 0 < alpha <= 1
+//	cumsum        running total
+//	scale(f)      multiply by f
+//	offset(f)     add f
+//	abs           absolute value
+//	clamp(lo,hi)  limit to [lo, hi]
+//	log           base-10 logarithm, or log(base); non-positive values are dropped
+//
+// Stateful stages (rate, delta, sma, ewma, cumsum) keep separate state per
+// label, so a route matching several series transforms each one on its own.
+func Parse(spec string) (Pipeline, error) {
+	var pl Pipeline
+	if strings.TrimSpace(spec) == "" {
+		return pl, nil
+	}
+
+	for _, stage := range strings.Split(spec, "|") {
+		name, args, err := splitStage(strings.TrimSpace(stage))
+		if err != nil {
+			return nil, err
+		}
+		t, err := newStage(name, args)
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %w", name, err)
+		}
+		pl = append(pl, t)
+	}
+	return pl, nil
+}
+
+// splitStage splits "name(a,b)" into its name and numeric arguments.
+func splitStage(s string) (string, []float64, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		if s == "" {
+			return "", nil, fmt.Errorf("empty transform stage")
+		}
+		return s, nil, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return "", nil, fmt.Errorf("invalid transform %q, missing )", s)
+	}
+
+	name := s[:open]
+	var args []float64
+	for _, a := range strings.Split(s[open+1:len(s)-1], ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(a), 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid argument %q in transform %q", a, s)
+		}
+		args = append(args, f)
+	}
+	return name, args, nil
+}
+
+// newStage creates a transform from its parsed name and arguments.
+func newStage(name string, args []float64) (Transform, error) {
+	want := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("expected %d argument(s), got %d", n, len(args))
+		}
+		return nil
+	}
+
+	switch name {
+	case "rate":
+		return newKeyed(func() Transform { return &Rate{} }), want(0)
+	case "delta":
+		return newKeyed(func() Transform { return &Delta{} }), want(0)
+	case "cumsum":
+		return newKeyed(func() Transform { return &CumSum{} }), want(0)
+	case "sma":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		n := int(args[0])
+		if n < 1 || float64(n) != args[0] {
+			return nil, fmt.Errorf("window must be a positive integer, got %g", args[0])
+		}
+		return newKeyed(func() Transform { return NewSMA(n) }), nil
+	case "ewma":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		if args[0] <= 0 || args[0] > 1 {
+			return nil, fmt.Errorf("alpha must be in (0, 1], got %g", args[0])
+		}
+		alpha := args[0]
+		return newKeyed(func() Transform { return &EWMA{Alpha: alpha} }), nil
+	case "scale":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		f := args[0]
+		return Func(func(v float64) (float64, bool) { return v * f, true }), nil
+	case "offset":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		f := args[0]
+		return Func(func(v float64) (float64, bool) { return v + f, true }), nil
+	case "abs":
+		return Func(func(v float64) (float64, bool) { return math.Abs(v), true }), want(0)
+	case "clamp":
+		if err := want(2); err != nil {
+			return nil, err
+		}
+		lo, hi := args[0], args[1]
+		if lo > hi {
+			return nil, fmt.Errorf("min %g is greater than max %g", lo, hi)
+		}
+		return Func(func(v float64) (float64, bool) { return math.Max(lo, math.Min(v, hi)), true }), nil
+	case "log":
+		base := 10.0
+		if len(args) > 0 {
+			if err := want(1); err != nil {
+				return nil, err
+			}
+			base = args[0]
+		}
+		if base <= 0 || base == 1 {
+			return nil, fmt.Errorf("invalid base %g", base)
+		}
+		lb := math.Log(base)
+		return Func(func(v float64) (float64, bool) {
+			if v <= 0 {
+				return 0, false
+			}
+			return math.Log(v) / lb, true
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown transform")
+}
+
+// Func is a stateless transform of the value alone.
+type Func func(v float64) (float64, bool)
+
+// Apply implements Transform.
+func (f Func) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	v, ok := f(p.Value)
+	p.Value = v
+	return p, ok
+}
+
+// keyed keeps a separate instance of a stateful transform per source and
+// label, so a counter's rate is not computed across two inputs.
+type keyed struct {
+	create func() Transform
+	byKey  map[seriesKey]Transform
+}
+
+type seriesKey struct {
+	source, label string
+}
+
+func newKeyed(create func() Transform) *keyed {
+	return &keyed{create: create, byKey: make(map[seriesKey]Transform)}
+}
+
+// Apply implements Transform.
+func (k *keyed) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	key := seriesKey{p.Source, p.Label}
+	t, ok := k.byKey[key]
+	if !ok {
+		t = k.create()
+		k.byKey[key] = t
+	}
+	return t.Apply(p)
+}
+
+// Rate converts a monotonic counter into a per-second rate using the points'
+// timestamps. A decrease is treated as a counter reset, so the new value is
+// taken as the increase since the reset. The first point is dropped, as are
+// points whose timestamp does not advance.
+type Rate struct {
+	prev    float64
+	prevAt  time.Time
+	started bool
+}
+
+// Apply implements Transform.
+func (r *Rate) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	prev, prevAt, started := r.prev, r.prevAt, r.started
+	elapsed := p.Timestamp.Sub(prevAt).Seconds()
+	if started && elapsed <= 0 {
+		return p, false
+	}
+	r.prev, r.prevAt, r.started = p.Value, p.Timestamp, true
+	if !started {
+		return p, false
+	}
+
+	increase := p.Value - prev
+	if increase < 0 {
+		increase = p.Value
+	}
+	p.Value = increase / elapsed
+	if p.Unit != "" {
+		p.Unit += "/s"
+	}
+	return p, true
+}
+
+// Delta replaces each value with its change from the previous one. The first
+// point is dropped.
+type Delta struct {
+	prev    float64
+	started bool
+}
+
+// Apply implements Transform.
+func (d *Delta) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	prev, started := d.prev, d.started
+	d.prev, d.started = p.Value, true
+	p.Value -= prev
+	return p, started
+}
+
+// CumSum replaces each value with the running total.
+type CumSum struct {
+	total float64
+}
+
+// Apply implements Transform.
+func (c *CumSum) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	c.total += p.Value
+	p.Value = c.total
+	return p, true
+}
+
+// SMA replaces each value with the mean of the last N values.
+type SMA struct {
+	values []float64
+	next   int
+	sum    float64
+}
+
+// NewSMA creates a simple moving average over n values.
+func NewSMA(n int) *SMA {
+	return &SMA{values: make([]float64, 0, max(n, 1))}
+}
+
+// Apply implements Transform.
+func (s *SMA) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	if len(s.values) < cap(s.values) {
+		s.values = append(s.values, p.Value)
+	} else {
+		s.sum -= s.values[s.next]
+		s.values[s.next] = p.Value
+		s.next = (s.next + 1) % len(s.values)
+	}
+	s.sum += p.Value
+	p.Value = s.sum / float64(len(s.values))
+	return p, true
+}
+
+// EWMA replaces each value with an exponentially weighted moving average.
+// Alpha is the weight of the newest value; the first value seeds the average.
+type EWMA struct {
+	Alpha   float64
+	avg     float64
+	started bool
+}
+
+// Apply implements Transform.
+func (e *EWMA) Apply(p stream.DataPoint) (stream.DataPoint, bool) {
+	if !e.started {
+		e.avg, e.started = p.Value, true
+	} else {
+		e.avg += e.Alpha * (p.Value - e.avg)
+	}
+	p.Value = e.avg
+	return p, true
+}
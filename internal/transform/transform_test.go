@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/danqzq/rift/internal/stream"
+)
+
+// run applies a pipeline spec to values spaced one second apart and returns
+// the values that were kept.
+func run(t *testing.T, spec string, values []float64) []float64 {
+	t.Helper()
+	pl, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", spec, err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out []float64
+	for i, v := range values {
+		p := stream.DataPoint{Value: v, Timestamp: start.Add(time.Duration(i) * time.Second)}
+		if p, ok := pl.Apply(p); ok {
+			out = append(out, p.Value)
+		}
+	}
+	return out
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		spec   string
+		values []float64
+		want   []float64
+	}{
+		{"", []float64{1, 2}, []float64{1, 2}},
+		{"rate", []float64{10, 15, 25}, []float64{5, 10}},
+		{"rate", []float64{100, 110, 4, 10}, []float64{10, 4, 6}}, // counter reset
+		{"delta", []float64{5, 3, 3, 10}, []float64{-2, 0, 7}},
+		{"cumsum", []float64{1, 2, 3}, []float64{1, 3, 6}},
+		{"sma(2)", []float64{2, 4, 6, 8}, []float64{2, 3, 5, 7}},
+		{"ewma(0.5)", []float64{10, 20, 20}, []float64{10, 15, 17.5}},
+		{"scale(2)|offset(-1)", []float64{1, 5}, []float64{1, 9}},
+		{"abs", []float64{-3, 3}, []float64{3, 3}},
+		{"clamp(0,10)", []float64{-5, 5, 50}, []float64{0, 5, 10}},
+		{"log", []float64{100, 0, 1000}, []float64{2, 3}},
+		{"log(2)", []float64{8}, []float64{3}},
+		{"cumsum | rate", []float64{1, 1, 1}, []float64{1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got := run(t, tt.spec, tt.values)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, spec := range []string{
+		"unknown",
+		"rate(1)",
+		"sma",
+		"sma(0)",
+		"sma(1.5)",
+		"ewma(2)",
+		"clamp(5,1)",
+		"scale(x)",
+		"log(1)",
+		"scale(2",
+		"rate||delta",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRate_PerLabel(t *testing.T) {
+	pl, err := Parse("rate")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []stream.DataPoint{
+		{Label: "rx", Value: 100, Timestamp: start, Unit: "B"},
+		{Label: "tx", Value: 5000, Timestamp: start},
+		{Label: "rx", Value: 120, Timestamp: start.Add(2 * time.Second), Unit: "B"},
+		{Label: "tx", Value: 5100, Timestamp: start.Add(2 * time.Second)},
+		{Label: "tx", Value: 5200, Timestamp: start.Add(2 * time.Second)}, // no time elapsed
+	}
+
+	got := make(map[string][]float64)
+	for _, p := range points {
+		if p, ok := pl.Apply(p); ok {
+			if p.Label == "rx" && p.Unit != "B/s" {
+				t.Errorf("rx unit = %q, want B/s", p.Unit)
+			}
+			got[p.Label] = append(got[p.Label], p.Value)
+		}
+	}
+	if len(got["rx"]) != 1 || got["rx"][0] != 10 {
+		t.Errorf("rx rates = %v, want [10]", got["rx"])
+	}
+	if len(got["tx"]) != 1 || got["tx"][0] != 50 {
+		t.Errorf("tx rates = %v, want [50]", got["tx"])
+	}
+}